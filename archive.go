@@ -3,6 +3,7 @@ package rardecode
 import (
 	"errors"
 	"hash"
+	"time"
 )
 
 const (
@@ -11,11 +12,20 @@ const (
 	decode29Ver
 	decode50Ver
 	decode70Ver
+	decode15Ver
 
+	archiveVersion14 = -1 // RAR 1.3 - 1.4 archives have their own signature
 	archiveVersion15 = 0
 	archiveVersion50 = 1
 )
 
+// ArchiveHeader Format values
+const (
+	ArchiveFormat14 = 14 // RAR 1.3 - 1.4 archive format
+	ArchiveFormat15 = 15 // RAR 1.5 - 4.x archive format
+	ArchiveFormat50 = 50 // RAR 5.0 and later archive format
+)
+
 var (
 	ErrCorruptBlockHeader    = errors.New("rardecode: corrupt block header")
 	ErrCorruptFileHeader     = errors.New("rardecode: corrupt file header")
@@ -78,6 +88,16 @@ func (b *readBuf) uvarint() uint64 {
 	return 0
 }
 
+// service block names
+const (
+	serviceComment   = "CMT" // archive comment
+	serviceQuickOpen = "QO"  // quick open header cache
+	serviceRecovery  = "RR"  // recovery record
+	serviceStream    = "STM" // NTFS alternate data stream
+	serviceACL       = "ACL" // NTFS security descriptor
+	serviceUnixOwner = "UOW" // RAR 3.x unix owner and group names
+)
+
 // fileBlockHeader represents a file block in a RAR archive.
 // Files may comprise one or more file blocks.
 // Solid files retain decode tables and dictionary from previous solid files in the archive.
@@ -94,17 +114,48 @@ type fileBlockHeader struct {
 	hashKey   []byte           // optional hmac key to be used calculate file checksum
 	sum       []byte           // expected checksum for file contents
 	decVer    int              // decoder to use for file
+	compInfo  uint64           // RAR 5 compression information
 	key       []byte           // key for AES, non-empty if file encrypted
 	iv        []byte           // iv for AES, non-empty if file encrypted
 	salt      []byte           // salt used for key derivation
 	kdfCount  int              // KDF iteration count (RAR5: 2^n, RAR3/4: 0x40000)
 	errs      []error          // errors to return when trying to read file body
+	service   string           // service block name, empty for file blocks
+	packed    []byte           // packed data stored in the block header rather than the archive
+	subData   []byte           // service specific data stored in a service block header
+	meta      *FileHeader      // file metadata read from the service blocks following the block
+	services  bool             // service blocks with file data, such as ACL or STM, follow the file
 	FileHeader
 }
 
+// ExtraRecord is a raw extra record of a RAR 5 block header.
+type ExtraRecord struct {
+	Type uint64 // record type
+	Data []byte // record data following the type
+}
+
+// ArchiveHeader represents the main archive header of a RAR archive volume.
+type ArchiveHeader struct {
+	Format          int           // archive format (ArchiveFormat14, ArchiveFormat15 or ArchiveFormat50)
+	Solid           bool          // archive is solid
+	MultiVolume     bool          // archive is part of a multi-volume set
+	FirstVolume     bool          // volume is known to be the first volume (always set for single volume archives)
+	VolumeNumber    int           // volume number starting from 0, or -1 if unknown
+	Locked          bool          // archive is locked against modification
+	HeaderEncrypted bool          // archive headers are encrypted
+	RecoveryRecord  bool          // archive has a recovery record
+	HasComment      bool          // archive header flags a comment (RAR 1.5 - 4.x only)
+	OriginalName    string        // original archive name (non-empty if set)
+	CreationTime    time.Time     // archive creation time (non-zero if set)
+	QuickOpenOffset int64         // offset of the quick open service block from the archive header (0 if not present)
+	RecoveryOffset  int64         // offset of the recovery record from the archive header (0 if not present)
+	ExtraRecords    []ExtraRecord // raw extra records of the archive header (RAR 5 only)
+}
+
 // archiveBlockReader returns the next fileBlockHeader in an archive volume.
 type archiveBlockReader interface {
 	init(br *bufVolumeReader) (int, error)                   // init volume and returns optional (>=0) volume number
 	nextBlock(br *bufVolumeReader) (*fileBlockHeader, error) // reads the volume and returns the next fileBlockHeader
 	useOldNaming() bool
+	archiveHeader() *ArchiveHeader // returns the main archive header of the current volume
 }
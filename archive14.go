@@ -0,0 +1,234 @@
+package rardecode
+
+import (
+	"encoding/binary"
+	"hash"
+	"io"
+	"slices"
+	"strings"
+)
+
+const (
+	sig14 = "RE~^" // RAR 1.3 - 1.4 archive signature
+
+	// archive block flags
+	arc14Volume      = 0x01
+	arc14Comment     = 0x02
+	arc14Locked      = 0x04
+	arc14Solid       = 0x08
+	arc14PackComment = 0x10
+
+	// file block flags
+	file14SplitBefore = 0x01
+	file14SplitAfter  = 0x02
+	file14Encrypted   = 0x04
+
+	file14HeaderSize = 21   // size of fixed part of file header
+	attr14Dir        = 0x10 // MS-DOS directory attribute
+	winSize14        = 0x10000
+)
+
+// checksum14 implements hash.Hash for the 16 bit checksum used by RAR 1.4 archives.
+type checksum14 struct {
+	sum uint16
+}
+
+func (c *checksum14) Write(p []byte) (int, error) {
+	for _, b := range p {
+		c.sum += uint16(b)
+		c.sum = c.sum<<1 | c.sum>>15
+	}
+	return len(p), nil
+}
+
+func (c *checksum14) Sum(b []byte) []byte { return append(b, byte(c.sum), byte(c.sum>>8)) }
+func (c *checksum14) Reset()              { c.sum = 0 }
+func (c *checksum14) Size() int           { return 2 }
+func (c *checksum14) BlockSize() int      { return 1 }
+
+func newChecksum14() hash.Hash { return new(checksum14) }
+
+// decryptComment13 decrypts an archive comment using the fixed key of RAR 1.3 comments.
+func decryptComment13(b []byte) {
+	var k0, k1, k2 byte = 0, 7, 77
+	for i := range b {
+		k1 += k2
+		k0 += k1
+		b[i] -= k0
+	}
+}
+
+// archive14 implements archiveBlockReader for RAR 1.3 and 1.4 archives.
+// These archives have a main header followed by file headers, with no block
+// types, header checksums or end of archive block.
+type archive14 struct {
+	multi    bool             // archive is multi-volume
+	solid    bool             // archive is a solid archive
+	fileRead bool             // a file block has been read from the archive
+	hdr      *ArchiveHeader   // main archive header of current volume
+	cmt      *fileBlockHeader // archive comment to be returned as a service block
+}
+
+func (a *archive14) useOldNaming() bool {
+	return true
+}
+
+func (a *archive14) archiveHeader() *ArchiveHeader {
+	return a.hdr
+}
+
+// parseComment parses the archive comment stored at the end of the main header.
+func (a *archive14) parseComment(flags byte, b readBuf) error {
+	if len(b) < 2 {
+		return ErrCorruptBlockHeader
+	}
+	n := int(b.uint16())
+	if len(b) < n {
+		return ErrCorruptBlockHeader
+	}
+	b = b[:n]
+	f := &fileBlockHeader{first: true, last: true, service: serviceComment}
+	f.Name = serviceComment
+	f.HostOS = HostOSMSDOS
+	if flags&arc14PackComment > 0 {
+		if len(b) < 2 {
+			return ErrCorruptBlockHeader
+		}
+		f.UnPackedSize = int64(b.uint16())
+		f.decVer = decode15Ver
+		f.winSize = winSize14
+		f.packed = slices.Clone(b)
+		decryptComment13(f.packed)
+	} else {
+		f.UnPackedSize = int64(n)
+		f.packed = slices.Clone(b)
+	}
+	f.PackedSize = int64(len(f.packed))
+	a.cmt = f
+	return nil
+}
+
+func (a *archive14) init(br *bufVolumeReader) (int, error) {
+	a.cmt = nil
+	// signature has already been read
+	b := make(readBuf, 3)
+	_, err := io.ReadFull(br, b)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return -1, err
+	}
+	size := int(b.uint16())
+	flags := b.byte()
+	if size < len(sig14)+3 {
+		return -1, ErrCorruptBlockHeader
+	}
+	b = make(readBuf, size-len(sig14)-3)
+	_, err = io.ReadFull(br, b)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return -1, err
+	}
+	a.multi = flags&arc14Volume > 0
+	a.solid = flags&arc14Solid > 0
+	a.hdr = &ArchiveHeader{
+		Format:       ArchiveFormat14,
+		Solid:        a.solid,
+		MultiVolume:  a.multi,
+		FirstVolume:  !a.multi,
+		VolumeNumber: -1,
+		Locked:       flags&arc14Locked > 0,
+		HasComment:   flags&arc14Comment > 0,
+	}
+	if !a.multi {
+		a.hdr.VolumeNumber = 0
+	}
+	if flags&arc14Comment > 0 {
+		if err = a.parseComment(flags, b); err != nil {
+			return -1, err
+		}
+	}
+	return -1, nil
+}
+
+func (a *archive14) parseFileHeader(h readBuf, name []byte) *fileBlockHeader {
+	f := new(fileBlockHeader)
+	f.PackedSize = int64(h.uint32())
+	f.UnPackedSize = int64(h.uint32())
+	f.sum = slices.Clone(h.bytes(2))
+	f.HashType = HashRAR14
+	f.Hash = f.sum
+	_ = h.uint16() // header size
+	f.ModificationTime = parseDosTime(h.uint32())
+	f.Attributes = int64(h.byte())
+	flags := h.byte()
+	_ = h.byte() // unpack version, all versions use the RAR 1.5 decoder
+	_ = h.byte() // name size
+	method := h.byte()
+
+	f.first = flags&file14SplitBefore == 0
+	f.last = flags&file14SplitAfter == 0
+	f.Encrypted = flags&file14Encrypted > 0
+	f.IsDir = f.Attributes&attr14Dir > 0
+	f.HostOS = HostOSMSDOS
+	f.arcSolid = a.solid
+	f.winSize = winSize14
+	f.Name = strings.Replace(string(name), "\\", "/", -1)
+
+	if !f.first {
+		return f
+	}
+	f.hash = newChecksum14
+	f.Solid = a.solid && a.fileRead
+	a.fileRead = true
+	if method != 0 {
+		f.decVer = decode15Ver
+	}
+	return f
+}
+
+// nextBlock advances to the next file block in the archive
+func (a *archive14) nextBlock(br *bufVolumeReader) (*fileBlockHeader, error) {
+	if a.cmt != nil {
+		f := a.cmt
+		a.cmt = nil
+		return f, nil
+	}
+	h := make(readBuf, file14HeaderSize)
+	_, err := io.ReadFull(br, h)
+	if err != nil {
+		if err == io.EOF {
+			// archive has no end block
+			if !a.multi {
+				return nil, io.EOF
+			}
+			return nil, errVolumeOrArchiveEnd
+		}
+		if err == io.ErrUnexpectedEOF {
+			err = ErrCorruptFileHeader
+		}
+		return nil, err
+	}
+	size := int(binary.LittleEndian.Uint16(h[10:]))
+	nameSize := int(h[19])
+	if size < file14HeaderSize+nameSize {
+		return nil, ErrCorruptFileHeader
+	}
+	b := make([]byte, size-file14HeaderSize)
+	_, err = io.ReadFull(br, b)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return a.parseFileHeader(h, b[:nameSize]), nil
+}
+
+// newArchive14 creates a new archiveBlockReader for a RAR 1.3 - 1.4 archive.
+func newArchive14() *archive14 {
+	return &archive14{}
+}
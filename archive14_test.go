@@ -0,0 +1,115 @@
+package rardecode
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"testing"
+	"testing/fstest"
+)
+
+// rar14File returns a RAR 1.4 file header followed by the packed file data.
+func rar14File(name string, attr, method byte, unpacked, packed []byte) []byte {
+	sum := new(checksum14)
+	_, _ = sum.Write(unpacked)
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len(packed)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(unpacked)))
+	b = sum.Sum(b)
+	b = binary.LittleEndian.AppendUint16(b, uint16(file14HeaderSize+len(name)))
+	b = binary.LittleEndian.AppendUint32(b, 0x21)      // file time
+	b = append(b, attr, 0, 2, byte(len(name)), method) // attributes, flags, version, name size, method
+	b = append(b, name...)
+	return append(b, packed...)
+}
+
+func TestArchive14(t *testing.T) {
+	packedABBB, _ := hex.DecodeString("8be5e600")
+	packedABA, _ := hex.DecodeString("77cbcd0040")
+	hello := []byte("hello, world\n")
+
+	// packed comment, encrypted with the fixed comment key
+	cmt := binary.LittleEndian.AppendUint16(nil, 4)
+	cmt = append(cmt, packedABBB...)
+	var k0, k1, k2 byte = 0, 7, 77
+	for i := 2; i < len(cmt); i++ {
+		k1 += k2
+		k0 += k1
+		cmt[i] += k0
+	}
+
+	arc := []byte(sig14)
+	arc = binary.LittleEndian.AppendUint16(arc, uint16(7+2+len(cmt)))
+	arc = append(arc, arc14Comment|arc14PackComment)
+	arc = binary.LittleEndian.AppendUint16(arc, uint16(len(cmt)))
+	arc = append(arc, cmt...)
+	arc = append(arc, rar14File("DOCS", attr14Dir, 0, nil, nil)...)
+	arc = append(arc, rar14File("DOCS\\HELLO.TXT", 0x20, 0, hello, hello)...)
+	arc = append(arc, rar14File("ABA.TXT", 0x20, 1, []byte("ABABABABABABA"), packedABA)...)
+
+	bad := append([]byte(nil), arc...)
+	bad[len(bad)-1] ^= 0xff
+
+	fsys := fstest.MapFS{
+		"test.rar": {Data: arc},
+		"bad.rar":  {Data: bad},
+	}
+	want := []struct {
+		name string
+		dir  bool
+		data string
+	}{
+		{"DOCS", true, ""},
+		{"DOCS/HELLO.TXT", false, string(hello)},
+		{"ABA.TXT", false, "ABABABABABABA"},
+	}
+
+	rc, err := OpenReader("test.rar", FileSystem(fsys))
+	if err != nil {
+		t.Fatalf("OpenReader() error = %v", err)
+	}
+	defer rc.Close()
+	if h := rc.ArchiveHeader(); h.Format != ArchiveFormat14 || !h.HasComment || h.MultiVolume {
+		t.Errorf("ArchiveHeader() = %+v", *h)
+	}
+	if c, err := rc.Comment(); c != "ABBB" || err != nil {
+		t.Errorf("Comment() = %q, %v, want %q", c, err, "ABBB")
+	}
+	for _, w := range want {
+		h, err := rc.Next()
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		if h.Name != w.name || h.IsDir != w.dir || h.HostOS != HostOSMSDOS || h.HashType != HashRAR14 {
+			t.Errorf("Next() = %+v, want %s", h, w.name)
+		}
+		b, err := io.ReadAll(rc)
+		if err != nil || string(b) != w.data {
+			t.Errorf("read %s = %q, %v, want %q", h.Name, b, err, w.data)
+		}
+	}
+	if _, err = rc.Next(); err != io.EOF {
+		t.Errorf("Next() error = %v, want %v", err, io.EOF)
+	}
+
+	rfs, err := OpenFS("test.rar", FileSystem(fsys))
+	if err != nil {
+		t.Fatalf("OpenFS() error = %v", err)
+	}
+	b, err := rfs.ReadFile("DOCS/HELLO.TXT")
+	if err != nil || string(b) != string(hello) {
+		t.Errorf("ReadFile() = %q, %v, want %q", b, err, hello)
+	}
+
+	fl, err := List("bad.rar", FileSystem(fsys))
+	if err != nil || len(fl) != len(want) {
+		t.Fatalf("List() = %d files, %v", len(fl), err)
+	}
+	r, err := fl[2].Open()
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+	if _, err = io.ReadAll(r); err == nil {
+		t.Error("reading corrupt file succeeded")
+	}
+}
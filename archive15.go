@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/sha1"
 	"errors"
+	"hash"
 	"hash/crc32"
 	"io"
 	"slices"
@@ -18,6 +19,7 @@ const (
 	blockArc     = 0x73
 	blockFile    = 0x74
 	blockComment = 0x75
+	blockProtect = 0x78 // RAR 2.x recovery record
 	blockService = 0x7a
 	blockEnd     = 0x7b
 
@@ -25,11 +27,14 @@ const (
 	blockHasData = 0x8000
 
 	// archive block flags
-	arcVolume    = 0x0001
-	arcComment   = 0x0002
-	arcSolid     = 0x0008
-	arcNewNaming = 0x0010
-	arcEncrypted = 0x0080
+	arcVolume      = 0x0001
+	arcComment     = 0x0002
+	arcLocked      = 0x0004
+	arcSolid       = 0x0008
+	arcNewNaming   = 0x0010
+	arcRecovery    = 0x0040
+	arcEncrypted   = 0x0080
+	arcFirstVolume = 0x0100
 
 	// file block flags
 	fileSplitBefore = 0x0001
@@ -55,11 +60,29 @@ var (
 	ErrUnsupportedDecoder = errors.New("rardecode: unsupported decoder version")
 )
 
+// leHash16 wraps a hash.Hash32 to return the low 16 bits of the result of
+// Sum in little endian format.
+type leHash16 struct {
+	hash.Hash32
+}
+
+func (h leHash16) Sum(b []byte) []byte {
+	s := h.Sum32()
+	return append(b, byte(s), byte(s>>8))
+}
+
+// newLittleEndianCRC16 returns the truncated CRC32 used by old style comments.
+func newLittleEndianCRC16() hash.Hash {
+	return leHash16{crc32.NewIEEE()}
+}
+
 type blockHeader15 struct {
 	htype    byte // block header type
 	flags    uint16
 	data     readBuf // header data
 	dataSize int64   // size of extra block data
+	raw      []byte  // whole block header
+	crcOK    bool    // header crc matches
 }
 
 // archive15 implements archiveBlockReader for RAR 1.5 file format archives
@@ -68,6 +91,8 @@ type archive15 struct {
 	solid     bool // archive is a solid archive
 	encrypted bool
 	oldNaming bool
+	fileRead  bool                  // a file block has been read from the archive
+	hdr       *ArchiveHeader        // main archive header of current volume
 	pass      []uint16              // password in UTF-16
 	keyCache  [cacheSize30]struct { // cache of previously calculated decryption keys
 		salt []byte
@@ -80,6 +105,10 @@ func (a *archive15) useOldNaming() bool {
 	return a.oldNaming
 }
 
+func (a *archive15) archiveHeader() *ArchiveHeader {
+	return a.hdr
+}
+
 // Calculates the key and iv for AES decryption given a password and salt.
 func calcAes30Params(pass []uint16, salt []byte) (key, iv []byte) {
 	p := make([]byte, 0, len(pass)*2+len(salt))
@@ -268,6 +297,8 @@ func (a *archive15) parseFileHeader(h *blockHeader15) (*fileBlockHeader, error)
 		f.HostOS = HostOSUnknown
 	}
 	f.sum = slices.Clone(b.bytes(4))
+	f.HashType = HashCRC32
+	f.Hash = f.sum
 
 	f.ModificationTime = parseDosTime(b.uint32())
 	unpackver := b.byte()     // decoder version
@@ -309,6 +340,17 @@ func (a *archive15) parseFileHeader(h *blockHeader15) (*fileBlockHeader, error)
 		}
 	}
 
+	if h.htype == blockService {
+		// remainder of the header, other than the salt, is service data
+		n := len(b)
+		if h.flags&fileSalt > 0 {
+			n -= saltSize
+		}
+		if n > 0 {
+			f.subData = slices.Clone(b.bytes(n))
+		}
+	}
+
 	var salt []byte
 	if h.flags&fileSalt > 0 {
 		if len(b) < saltSize {
@@ -331,10 +373,50 @@ func (a *archive15) parseFileHeader(h *blockHeader15) (*fileBlockHeader, error)
 		f.key, f.iv = a.getKeys(salt)
 	}
 	f.hash = newLittleEndianCRC32
+	if unpackver == 15 {
+		// RAR 1.5 files have no solid flag, every file after the first
+		// in a solid archive is solid.
+		f.Solid = a.solid && a.fileRead
+	}
+	if h.htype == blockFile {
+		a.fileRead = true
+	}
+	if method != 0 {
+		switch unpackver {
+		case 15:
+			f.decVer = decode15Ver
+		case 20, 26:
+			f.decVer = decode20Ver
+		case 29:
+			f.decVer = decode29Ver
+		default:
+			return nil, ErrUnknownDecoder
+		}
+	}
+	return f, nil
+}
+
+// parseCommentBlock parses an old style (RAR 2.x) archive comment block.
+// The packed comment is stored in the block header rather than as block data.
+func (a *archive15) parseCommentBlock(h *blockHeader15) (*fileBlockHeader, error) {
+	b := h.data
+	if len(b) < 6 {
+		return nil, ErrCorruptBlockHeader
+	}
+	f := &fileBlockHeader{first: true, last: true, service: serviceComment}
+	f.Name = serviceComment
+	f.UnPackedSize = int64(b.uint16())
+	unpackver := b.byte()
+	method := b.byte() - 0x30
+	f.sum = slices.Clone(b.bytes(2))
+	f.hash = newLittleEndianCRC16
+	f.packed = slices.Clone(b)
+	f.PackedSize = int64(len(f.packed))
+	f.winSize = 0x10000
 	if method != 0 {
 		switch unpackver {
 		case 15:
-			return nil, ErrUnsupportedDecoder
+			f.decVer = decode15Ver
 		case 20, 26:
 			f.decVer = decode20Ver
 		case 29:
@@ -346,11 +428,35 @@ func (a *archive15) parseFileHeader(h *blockHeader15) (*fileBlockHeader, error)
 	return f, nil
 }
 
+// parseProtectBlock returns an old style (RAR 2.x) recovery record as a
+// recovery record service block.
+func (a *archive15) parseProtectBlock(h *blockHeader15) *fileBlockHeader {
+	f := &fileBlockHeader{first: true, last: true, service: serviceRecovery}
+	f.Name = serviceRecovery
+	f.PackedSize = h.dataSize
+	f.subData = slices.Clone(h.data)
+	return f
+}
+
 func (a *archive15) parseArcBlock(h *blockHeader15) error {
 	a.encrypted = h.flags&arcEncrypted > 0
 	a.multi = h.flags&arcVolume > 0
 	a.oldNaming = h.flags&arcNewNaming == 0
 	a.solid = h.flags&arcSolid > 0
+	a.hdr = &ArchiveHeader{
+		Format:          ArchiveFormat15,
+		Solid:           a.solid,
+		MultiVolume:     a.multi,
+		FirstVolume:     !a.multi || h.flags&arcFirstVolume > 0,
+		VolumeNumber:    -1,
+		Locked:          h.flags&arcLocked > 0,
+		HeaderEncrypted: a.encrypted,
+		RecoveryRecord:  h.flags&arcRecovery > 0,
+		HasComment:      h.flags&arcComment > 0,
+	}
+	if a.hdr.FirstVolume {
+		a.hdr.VolumeNumber = 0
+	}
 	if a.encrypted && a.pass == nil {
 		return ErrArchiveEncrypted
 	}
@@ -360,6 +466,17 @@ func (a *archive15) parseArcBlock(h *blockHeader15) error {
 // readBlockHeader returns the next block header in the archive.
 // It will return io.EOF if there were no bytes read.
 func (a *archive15) readBlockHeader(r byteReader) (*blockHeader15, error) {
+	h, err := a.readRawBlockHeader(r)
+	if err == nil && !h.crcOK {
+		return nil, ErrBadHeaderCRC
+	}
+	return h, err
+}
+
+// readRawBlockHeader returns the next block header in the archive without
+// failing if the header crc doesn't match. Any header corruption found after
+// a crc mismatch is reported as ErrBadHeaderCRC.
+func (a *archive15) readRawBlockHeader(r byteReader) (*blockHeader15, error) {
 	if a.encrypted {
 		if a.pass == nil {
 			return nil, ErrArchiveEncrypted
@@ -417,19 +534,22 @@ func (a *archive15) readBlockHeader(r byteReader) (*blockHeader15, error) {
 	} else {
 		_, _ = hash.Write(h.data[2:])
 	}
-	if crc != uint16(hash.Sum32()) {
-		return nil, ErrBadHeaderCRC
+	h.crcOK = crc == uint16(hash.Sum32())
+	errCorrupt := ErrCorruptBlockHeader
+	if !h.crcOK {
+		errCorrupt = ErrBadHeaderCRC
 	}
+	h.raw = h.data
 	h.data = h.data[7:]
 	if h.flags&blockHasData > 0 {
 		if len(h.data) < 4 {
-			return nil, ErrCorruptBlockHeader
+			return nil, errCorrupt
 		}
 		h.dataSize = int64(h.data.uint32())
 	}
 	if (h.htype == blockService || h.htype == blockFile) && h.flags&fileLargeData > 0 {
 		if len(h.data) < 25 {
-			return nil, ErrCorruptBlockHeader
+			return nil, errCorrupt
 		}
 		b := h.data[21:25]
 		h.dataSize |= int64(b.uint32()) << 32
@@ -466,6 +586,28 @@ func (a *archive15) nextBlock(br *bufVolumeReader) (*fileBlockHeader, error) {
 		switch h.htype {
 		case blockFile:
 			return a.parseFileHeader(h)
+		case blockService:
+			f, err := a.parseFileHeader(h)
+			if err == nil {
+				f.service = f.Name
+				return f, nil
+			} else if err != ErrUnknownDecoder {
+				return nil, err
+			}
+			// skip service blocks using an unknown decoder
+			if h.dataSize > 0 {
+				err = br.Discard(h.dataSize)
+				if err != nil {
+					return nil, err
+				}
+			}
+		case blockComment:
+			f, err := a.parseCommentBlock(h)
+			if err != ErrUnknownDecoder {
+				return f, err
+			}
+		case blockProtect:
+			return a.parseProtectBlock(h), nil
 		case blockEnd:
 			if h.flags&endArcNotLast == 0 || !a.multi {
 				return nil, io.EOF
@@ -482,6 +624,31 @@ func (a *archive15) nextBlock(br *bufVolumeReader) (*fileBlockHeader, error) {
 	}
 }
 
+// readRawBlock reads the next block header in the volume for a BlockWalker.
+// The block data is left unread.
+func (a *archive15) readRawBlock(br *bufVolumeReader) (*Block, error) {
+	off := br.off
+	h, err := a.readRawBlockHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	if h.htype == blockArc && h.crcOK {
+		// archive block flags whether the following block headers are encrypted
+		err = a.parseArcBlock(h)
+	}
+	return &Block{
+		Format:       ArchiveFormat15,
+		Type:         uint64(h.htype),
+		Flags:        uint64(h.flags),
+		HeaderOffset: off,
+		HeaderSize:   br.off - off,
+		DataOffset:   br.off,
+		DataSize:     h.dataSize,
+		CRCValid:     h.crcOK,
+		Header:       h.raw,
+	}, err
+}
+
 // newArchive15 creates a new archiveBlockReader for a Version 1.5 archive
 func newArchive15(password *string) *archive15 {
 	a := &archive15{}
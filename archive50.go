@@ -15,9 +15,9 @@ import (
 
 const (
 	// block types
-	block5Arc  = 1
-	block5File = 2
-	// block5Service = 3
+	block5Arc     = 1
+	block5File    = 2
+	block5Service = 3
 	block5Encrypt = 4
 	block5End     = 5
 
@@ -37,6 +37,18 @@ const (
 	arc5MultiVol = 0x0001
 	arc5VolNum   = 0x0002
 	arc5Solid    = 0x0004
+	arc5Recovery = 0x0008
+	arc5Locked   = 0x0010
+
+	// main archive locator record flags
+	locator5QuickOpen = 0x0001 // quick open offset present
+	locator5Recovery  = 0x0002 // recovery record offset present
+
+	// main archive metadata record flags
+	meta5Name     = 0x0001 // archive name present
+	meta5Time     = 0x0002 // archive creation time present
+	meta5UnixTime = 0x0004 // time is in unix format
+	meta5UnixNS   = 0x0008 // unix time has nanosecond precision
 
 	// file block flags
 	file5IsDir          = 0x0001
@@ -52,6 +64,18 @@ const (
 	file5CompDictFract = 0x000F8000
 	file5CompV5Compat  = 0x00100000
 
+	// file hash record types
+	hash5Blake2sp = 0
+
+	// file redirection record flags
+	file5RedirIsDir = 0x0001 // link target is a directory
+
+	// file owner record flags
+	file5OwnerUserName  = 0x0001
+	file5OwnerGroupName = 0x0002
+	file5OwnerUID       = 0x0004
+	file5OwnerGID       = 0x0008
+
 	// file encryption record flags
 	file5EncCheckPresent = 0x0001 // password check data is present
 	file5EncUseMac       = 0x0002 // use MAC instead of plain checksum
@@ -69,6 +93,8 @@ const (
 	maxKdfCount   = 24
 
 	maxDictSize = 0x1000000000 // maximum dictionary size 64GB
+
+	maxHeaderSize50 = 1 << 20 // maximum size of a block header
 )
 
 var (
@@ -79,6 +105,7 @@ var (
 	ErrDictionaryTooLarge   = errors.New("rardecode: decode dictionary too large")
 	ErrBadVolumeNumber      = errors.New("rardecode: bad volume number")
 	ErrNoArchiveBlock       = errors.New("rardecode: missing archive block")
+	errCorruptQuickOpen     = errors.New("rardecode: corrupt quick open data")
 )
 
 type extra struct {
@@ -86,12 +113,24 @@ type extra struct {
 	data  readBuf // field data
 }
 
+// extraRecords returns a copy of the extra records of a block header.
+func extraRecords(extra []extra) []ExtraRecord {
+	var records []ExtraRecord
+	for _, e := range extra {
+		records = append(records, ExtraRecord{Type: e.ftype, Data: slices.Clone(e.data)})
+	}
+	return records
+}
+
 type blockHeader50 struct {
 	htype    uint64 // block type
 	flags    uint64
 	data     readBuf // block header data
 	extra    []extra // extra fields
 	dataSize int64   // size of block data
+	size     int     // size of unencrypted block header
+	raw      []byte  // whole block header
+	crcOK    bool    // header crc matches
 }
 
 // leHash32 wraps a hash.Hash32 to return the result of Sum in little
@@ -115,6 +154,9 @@ type archive50 struct {
 	blockKey []byte                // key used to encrypt blocks
 	multi    bool                  // archive is multi-volume
 	solid    bool                  // is a solid archive
+	hdr      *ArchiveHeader        // main archive header of current volume
+	qo       []quickOpenRecord     // quick open cache of block headers in volume order
+	qoi      int                   // index of next quick open record
 	keyCache [cacheSize50]struct { // encryption key cache
 		kdfCount int
 		salt     []byte
@@ -126,6 +168,10 @@ func (a *archive50) useOldNaming() bool {
 	return false
 }
 
+func (a *archive50) archiveHeader() *ArchiveHeader {
+	return a.hdr
+}
+
 // calcKeys50 calculates the keys used in RAR 5 archive processing.
 // The returned slice of byte slices contains 3 keys.
 // Key 0 is used for block or file decryption.
@@ -252,6 +298,76 @@ func (a *archive50) parseFileEncryptionRecord(b readBuf, f *fileBlockHeader) err
 	return nil
 }
 
+// parseFileHashRecord processes the optional file hash record from a file header.
+func (a *archive50) parseFileHashRecord(b readBuf, f *fileBlockHeader) error {
+	if htype := b.uvarint(); htype != hash5Blake2sp {
+		return nil // ignore unknown hash types
+	}
+	if len(b) < blake2sSize {
+		return ErrCorruptFileHeader
+	}
+	f.sum = slices.Clone(b.bytes(blake2sSize))
+	f.HashType = HashBLAKE2sp
+	f.Hash = f.sum
+	if f.first {
+		f.hash = newBlake2sp
+	}
+	return nil
+}
+
+// parseFileRedirectionRecord processes the optional file system redirection record from a file header.
+func (a *archive50) parseFileRedirectionRecord(b readBuf, f *fileBlockHeader) error {
+	ltype := b.uvarint()
+	flags := b.uvarint()
+	nlen := int(b.uvarint())
+	if len(b) < nlen {
+		return ErrCorruptFileHeader
+	}
+	if ltype < LinkTypeUnixSymlink || ltype > LinkTypeFileCopy {
+		return nil // ignore unknown link types
+	}
+	f.LinkType = int(ltype)
+	f.LinkTarget = string(b.bytes(nlen))
+	f.LinkTargetIsDir = flags&file5RedirIsDir > 0
+	return nil
+}
+
+// parseFileOwnerRecord processes the optional unix owner record from a file header.
+func (a *archive50) parseFileOwnerRecord(b readBuf, f *fileBlockHeader) error {
+	flags := b.uvarint()
+	if flags&file5OwnerUserName > 0 {
+		n := int(b.uvarint())
+		if len(b) < n {
+			return ErrCorruptFileHeader
+		}
+		f.UserName = string(b.bytes(n))
+		f.HasUserName = true
+	}
+	if flags&file5OwnerGroupName > 0 {
+		n := int(b.uvarint())
+		if len(b) < n {
+			return ErrCorruptFileHeader
+		}
+		f.GroupName = string(b.bytes(n))
+		f.HasGroupName = true
+	}
+	if flags&file5OwnerUID > 0 {
+		if len(b) == 0 {
+			return ErrCorruptFileHeader
+		}
+		f.UID = int(b.uvarint())
+		f.HasUID = true
+	}
+	if flags&file5OwnerGID > 0 {
+		if len(b) == 0 {
+			return ErrCorruptFileHeader
+		}
+		f.GID = int(b.uvarint())
+		f.HasGID = true
+	}
+	return nil
+}
+
 func readWinFiletime(b *readBuf) (time.Time, error) {
 	if len(*b) < 8 {
 		return time.Time{}, ErrCorruptFileHeader
@@ -366,12 +482,15 @@ func (a *archive50) parseFileHeader(h *blockHeader50) (*fileBlockHeader, error)
 			return nil, ErrCorruptFileHeader
 		}
 		f.sum = slices.Clone(h.data.bytes(4))
+		f.HashType = HashCRC32
+		f.Hash = f.sum
 		if f.first {
 			f.hash = newLittleEndianCRC32
 		}
 	}
 
 	flags = h.data.uvarint() // compression flags
+	f.compInfo = flags
 	f.Solid = flags&file5CompSolid > 0
 	f.arcSolid = a.solid
 	method := (flags >> 7) & 7 // compression method (0 == none)
@@ -408,6 +527,7 @@ func (a *archive50) parseFileHeader(h *blockHeader50) (*fileBlockHeader, error)
 	f.Name = string(h.data.bytes(nlen))
 
 	// parse optional extra records
+	f.ExtraRecords = extraRecords(h.extra)
 	for _, e := range h.extra {
 		var err error
 		switch e.ftype {
@@ -415,17 +535,19 @@ func (a *archive50) parseFileHeader(h *blockHeader50) (*fileBlockHeader, error)
 			if encErr := a.parseFileEncryptionRecord(e.data, f); encErr != nil {
 				f.errs = append(f.errs, encErr)
 			}
-		case 2:
-			// TODO: hash
+		case 2: // hash
+			err = a.parseFileHashRecord(e.data, f)
 		case 3:
 			err = a.parseFilePrecisionTimeRecord(&e.data, f)
 		case 4: // version
 			_ = e.data.uvarint() // ignore flags field
 			f.Version = int(e.data.uvarint())
-		case 5:
-			// TODO: redirection
-		case 6:
-			// TODO: owner
+		case 5: // redirection
+			err = a.parseFileRedirectionRecord(e.data, f)
+		case 6: // owner
+			err = a.parseFileOwnerRecord(e.data, f)
+		case 7: // service data
+			f.subData = slices.Clone(e.data)
 		}
 		if err != nil {
 			return nil, err
@@ -465,17 +587,95 @@ func (a *archive50) parseEncryptionBlock(b readBuf) error {
 	return nil
 }
 
-func (a *archive50) parseArcBlock(h *blockHeader50) int {
+// parseArcLocatorRecord processes the optional locator record from the main archive header.
+func (a *archive50) parseArcLocatorRecord(b readBuf) {
+	flags := b.uvarint()
+	if flags&locator5QuickOpen > 0 {
+		a.hdr.QuickOpenOffset = int64(b.uvarint())
+	}
+	if flags&locator5Recovery > 0 {
+		a.hdr.RecoveryOffset = int64(b.uvarint())
+	}
+}
+
+// parseArcMetadataRecord processes the optional metadata record from the main archive header.
+func (a *archive50) parseArcMetadataRecord(b readBuf) error {
+	var err error
+	flags := b.uvarint()
+	if flags&meta5Name > 0 {
+		n := int(b.uvarint())
+		if len(b) < n {
+			return ErrCorruptBlockHeader
+		}
+		name := b.bytes(n)
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		a.hdr.OriginalName = string(name)
+	}
+	if flags&meta5Time > 0 {
+		switch {
+		case flags&meta5UnixTime == 0:
+			a.hdr.CreationTime, err = readWinFiletime(&b)
+		case flags&meta5UnixNS > 0:
+			if len(b) < 8 {
+				return ErrCorruptBlockHeader
+			}
+			a.hdr.CreationTime = time.Unix(0, int64(b.uint64()))
+		default:
+			a.hdr.CreationTime, err = readUnixTime(&b)
+		}
+		if err != nil {
+			return ErrCorruptBlockHeader
+		}
+	}
+	return nil
+}
+
+func (a *archive50) parseArcBlock(h *blockHeader50) (int, error) {
 	flags := h.data.uvarint()
 	a.multi = flags&arc5MultiVol > 0
 	a.solid = flags&arc5Solid > 0
+	a.hdr = &ArchiveHeader{
+		Format:          ArchiveFormat50,
+		Solid:           a.solid,
+		MultiVolume:     a.multi,
+		FirstVolume:     flags&arc5VolNum == 0,
+		Locked:          flags&arc5Locked > 0,
+		HeaderEncrypted: a.blockKey != nil,
+		RecoveryRecord:  flags&arc5Recovery > 0,
+	}
+	volnum := -1
 	if flags&arc5VolNum > 0 {
-		return int(h.data.uvarint())
+		volnum = int(h.data.uvarint())
+		a.hdr.VolumeNumber = volnum
+	}
+	a.hdr.ExtraRecords = extraRecords(h.extra)
+	for _, e := range h.extra {
+		switch e.ftype {
+		case 1: // locator
+			a.parseArcLocatorRecord(e.data)
+		case 2: // metadata
+			if err := a.parseArcMetadataRecord(e.data); err != nil {
+				return volnum, err
+			}
+		}
 	}
-	return -1
+	return volnum, nil
 }
 
 func (a *archive50) readBlockHeader(r byteReader) (*blockHeader50, error) {
+	h, err := a.readRawBlockHeader(r)
+	if err == nil && !h.crcOK {
+		return nil, ErrBadHeaderCRC
+	}
+	return h, err
+}
+
+// readRawBlockHeader returns the next block header in the archive without
+// failing if the header crc doesn't match. Any header corruption found after
+// a crc mismatch is reported as ErrBadHeaderCRC.
+func (a *archive50) readRawBlockHeader(r byteReader) (*blockHeader50, error) {
 	if a.blockKey != nil {
 		// block is encrypted
 		if a.pass == nil {
@@ -508,13 +708,13 @@ func (a *archive50) readBlockHeader(r byteReader) (*blockHeader50, error) {
 	}
 	// Prevent excessive memory allocation from corrupt headers.
 	// RAR5 block headers should not exceed a reasonable size.
-	const maxHeaderSize = 1 << 20 // 1MB
-	if size > maxHeaderSize {
+	if size > maxHeaderSize50 {
 		return nil, ErrCorruptBlockHeader
 	}
 
-	buf := make([]byte, 3+size-len(b))
-	copy(buf, sizeBuf[4:])
+	raw := make([]byte, 7+size-len(b))
+	copy(raw, sizeBuf)
+	buf := raw[4:]
 	_, err = io.ReadFull(r, buf[3:])
 	if err != nil {
 		return nil, err
@@ -523,12 +723,16 @@ func (a *archive50) readBlockHeader(r byteReader) (*blockHeader50, error) {
 	// check header crc
 	hash := crc32.NewIEEE()
 	_, _ = hash.Write(buf)
-	if crc != hash.Sum32() {
-		return nil, ErrBadHeaderCRC
+	h := new(blockHeader50)
+	h.crcOK = crc == hash.Sum32()
+	errCorrupt := ErrCorruptBlockHeader
+	if !h.crcOK {
+		errCorrupt = ErrBadHeaderCRC
 	}
 
 	b = buf[3-len(b):]
-	h := new(blockHeader50)
+	h.size = 4 + len(buf)
+	h.raw = raw
 	h.htype = b.uvarint()
 	h.flags = b.uvarint()
 
@@ -540,7 +744,7 @@ func (a *archive50) readBlockHeader(r byteReader) (*blockHeader50, error) {
 		h.dataSize = int64(b.uvarint())
 	}
 	if len(b) < extraSize {
-		return nil, ErrCorruptBlockHeader
+		return nil, errCorrupt
 	}
 	h.data = b.bytes(len(b) - extraSize)
 
@@ -548,7 +752,7 @@ func (a *archive50) readBlockHeader(r byteReader) (*blockHeader50, error) {
 	for len(b) > 0 {
 		size = int(b.uvarint())
 		if len(b) < size {
-			return nil, ErrCorruptBlockHeader
+			return nil, errCorrupt
 		}
 		data := readBuf(b.bytes(size))
 		ftype := data.uvarint()
@@ -569,9 +773,105 @@ func (a *archive50) mustReadBlockHeader(r byteReader) (*blockHeader50, error) {
 	return h, nil
 }
 
+// readQuickOpenRecord reads a single cached header record from quick open data.
+// It returns the size of the record and the header and its offset relative
+// to the quick open block.
+func readQuickOpenRecord(r io.Reader, n int64) (int64, []byte, int64, error) {
+	// record crc and start of size field
+	buf := make([]byte, 4, 16)
+	if n < 5 {
+		return 0, nil, 0, errCorruptQuickOpen
+	}
+	_, err := io.ReadFull(r, buf[:5])
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	buf = buf[:5]
+	for buf[len(buf)-1]&0x80 > 0 {
+		if len(buf) == cap(buf) || int64(len(buf)) >= n {
+			return 0, nil, 0, errCorruptQuickOpen
+		}
+		buf = buf[:len(buf)+1]
+		_, err = io.ReadFull(r, buf[len(buf)-1:])
+		if err != nil {
+			return 0, nil, 0, err
+		}
+	}
+	b := readBuf(buf)
+	crc := b.uint32()
+	size := int64(b.uvarint())
+	n -= int64(len(buf))
+	if size > n || size > maxHeaderSize50+32 {
+		return 0, nil, 0, errCorruptQuickOpen
+	}
+	buf = append(buf, make([]byte, size)...)
+	_, err = io.ReadFull(r, buf[len(buf)-int(size):])
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	if crc32.ChecksumIEEE(buf[4:]) != crc {
+		return 0, nil, 0, errCorruptQuickOpen
+	}
+	b = buf[len(buf)-int(size):]
+	_ = b.uvarint() // flags
+	off := int64(b.uvarint())
+	hsize := int(b.uvarint())
+	if hsize > len(b) || off <= 0 {
+		return 0, nil, 0, errCorruptQuickOpen
+	}
+	return int64(len(buf)), b.bytes(hsize), off, nil
+}
+
+// quickOpenRecord is a block header cached in the quick open service block.
+type quickOpenRecord struct {
+	off int64  // volume offset of the block
+	hdr []byte // raw block header
+}
+
+// readQuickOpen loads the cache of block headers stored in the quick open
+// service block at offset off in the current volume.
+func (a *archive50) readQuickOpen(br *bufVolumeReader, off int64) error {
+	err := br.seek(off)
+	if err != nil {
+		return err
+	}
+	h, err := a.mustReadBlockHeader(br)
+	if err != nil {
+		return err
+	}
+	if h.htype != block5Service {
+		return errCorruptQuickOpen
+	}
+	f, err := a.parseFileHeader(h)
+	if err != nil {
+		return err
+	}
+	if f.Name != serviceQuickOpen || f.decVer != 0 || f.Encrypted || !f.first || !f.last {
+		return errCorruptQuickOpen
+	}
+	var qo []quickOpenRecord
+	for n := h.dataSize; n > 0; {
+		size, hdr, hoff, err := readQuickOpenRecord(br, n)
+		if err != nil {
+			return err
+		}
+		rec := quickOpenRecord{off: off - hoff, hdr: hdr}
+		if len(qo) > 0 && rec.off <= qo[len(qo)-1].off {
+			return errCorruptQuickOpen
+		}
+		qo = append(qo, rec)
+		n -= size
+	}
+	a.qo = qo
+	return nil
+}
+
 func (a *archive50) init(br *bufVolumeReader) (int, error) {
 	a.blockKey = nil // reset encryption when opening new volume file
+	a.qo = nil
+	a.qoi = 0
 	volnum := -1
+	arcOff := br.off
 	h, err := a.mustReadBlockHeader(br)
 	if err != nil {
 		return volnum, err
@@ -581,6 +881,7 @@ func (a *archive50) init(br *bufVolumeReader) (int, error) {
 		if err != nil {
 			return volnum, err
 		}
+		arcOff = br.off
 		h, err = a.mustReadBlockHeader(br)
 		if err != nil {
 			return volnum, err
@@ -589,21 +890,66 @@ func (a *archive50) init(br *bufVolumeReader) (int, error) {
 	if h.htype != block5Arc {
 		return volnum, ErrNoArchiveBlock
 	}
-	volnum = a.parseArcBlock(h)
-	return volnum, nil
+	volnum, err = a.parseArcBlock(h)
+	if err != nil || a.hdr.QuickOpenOffset == 0 || a.blockKey != nil || !br.canSeek() {
+		return volnum, err
+	}
+	// Quick open data is only an optimization, if it can't be read
+	// the volume is read block by block.
+	pos := br.off
+	if a.readQuickOpen(br, arcOff+a.hdr.QuickOpenOffset) != nil {
+		a.qo = nil
+	}
+	return volnum, br.seek(pos)
+}
+
+// readCachedBlockHeader returns the next block header in the volume.
+// While quick open records remain, headers are taken from the cache in order
+// and the volume reader is positioned at the block data without reading
+// the blocks in between. Once the cache is used up, or a cached header is
+// damaged, headers are read from the volume.
+func (a *archive50) readCachedBlockHeader(br *bufVolumeReader) (*blockHeader50, error) {
+	if a.qoi < len(a.qo) {
+		rec := a.qo[a.qoi]
+		a.qoi++
+		if rec.off >= br.off {
+			h, err := a.readBlockHeader(bytes.NewReader(rec.hdr))
+			if err == nil && h.size == len(rec.hdr) {
+				return h, br.seek(rec.off + int64(len(rec.hdr)))
+			}
+		}
+		// damaged cache, fall back to reading block headers from the volume
+		a.qo = nil
+	}
+	return a.mustReadBlockHeader(br)
 }
 
 // nextBlock advances to the next file block in the archive
 func (a *archive50) nextBlock(br *bufVolumeReader) (*fileBlockHeader, error) {
 	for {
 		// get next block header
-		h, err := a.mustReadBlockHeader(br)
+		h, err := a.readCachedBlockHeader(br)
 		if err != nil {
 			return nil, err
 		}
 		switch h.htype {
 		case block5File:
 			return a.parseFileHeader(h)
+		case block5Service:
+			f, err := a.parseFileHeader(h)
+			if err == nil {
+				f.service = f.Name
+				return f, nil
+			} else if err != ErrUnknownDecoder {
+				return nil, err
+			}
+			// skip service blocks using an unknown decoder
+			if h.dataSize > 0 {
+				err = br.Discard(h.dataSize)
+				if err != nil {
+					return nil, err
+				}
+			}
 		case block5End:
 			flags := h.data.uvarint()
 			if flags&endArc5NotLast == 0 || !a.multi {
@@ -621,6 +967,39 @@ func (a *archive50) nextBlock(br *bufVolumeReader) (*fileBlockHeader, error) {
 	}
 }
 
+// readRawBlock reads the next block header in the volume for a BlockWalker.
+// The block data is left unread.
+func (a *archive50) readRawBlock(br *bufVolumeReader) (*Block, error) {
+	off := br.off
+	h, err := a.readRawBlockHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	if h.crcOK {
+		switch h.htype {
+		case block5Encrypt:
+			// following block headers are encrypted
+			err = a.parseEncryptionBlock(h.data)
+		case block5Arc:
+			// a damaged metadata record doesn't stop the following blocks being read
+			_, _ = a.parseArcBlock(h)
+		}
+	}
+	b := &Block{
+		Format:       ArchiveFormat50,
+		Type:         h.htype,
+		Flags:        h.flags,
+		HeaderOffset: off,
+		HeaderSize:   br.off - off,
+		DataOffset:   br.off,
+		DataSize:     h.dataSize,
+		CRCValid:     h.crcOK,
+		Header:       h.raw,
+	}
+	b.Extra = extraRecords(h.extra)
+	return b, err
+}
+
 // newArchive50 creates a new archiveBlockReader for a Version 5 archive.
 func newArchive50(password *string) *archive50 {
 	a := &archive50{}
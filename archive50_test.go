@@ -3,7 +3,14 @@ package rardecode
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"reflect"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 // TestReadBlockHeader_MalformedSize tests that malformed RAR5 block headers
@@ -167,3 +174,291 @@ func TestReadBlockHeader_ExactPanicScenario(t *testing.T) {
 		t.Fatalf("readBlockHeader() error = %v, want %v (old code would panic here)", err, ErrCorruptBlockHeader)
 	}
 }
+
+func TestParseFileRedirectionRecord(t *testing.T) {
+	target := "proverbs/extra/proverb3.txt"
+	rec := append([]byte{LinkTypeUnixSymlink, 0, byte(len(target))}, target...)
+
+	a := &archive50{}
+	f := &fileBlockHeader{}
+	f.HostOS = HostOSUnix
+	if err := a.parseFileRedirectionRecord(rec, f); err != nil {
+		t.Fatalf("parseFileRedirectionRecord() error = %v", err)
+	}
+	if f.LinkType != LinkTypeUnixSymlink || f.LinkTarget != target || f.LinkTargetIsDir {
+		t.Errorf("parseFileRedirectionRecord() = %d %q %v", f.LinkType, f.LinkTarget, f.LinkTargetIsDir)
+	}
+	if f.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("Mode() = %v, want symlink", f.Mode())
+	}
+
+	f = &fileBlockHeader{}
+	rec = append([]byte{LinkTypeJunction, file5RedirIsDir, byte(len(target))}, target...)
+	if err := a.parseFileRedirectionRecord(rec, f); err != nil {
+		t.Fatalf("parseFileRedirectionRecord() error = %v", err)
+	}
+	if !f.LinkTargetIsDir || f.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("junction LinkTargetIsDir = %v, Mode() = %v", f.LinkTargetIsDir, f.Mode())
+	}
+
+	err := a.parseFileRedirectionRecord([]byte{LinkTypeHardLink, 0, 10, 'a'}, &fileBlockHeader{})
+	if err != ErrCorruptFileHeader {
+		t.Errorf("parseFileRedirectionRecord() short name error = %v, want %v", err, ErrCorruptFileHeader)
+	}
+}
+
+func TestParseFileOwnerRecord(t *testing.T) {
+	rec := []byte{file5OwnerUserName | file5OwnerGroupName | file5OwnerUID | file5OwnerGID,
+		4, 'r', 'o', 'o', 't', 5, 'w', 'h', 'e', 'e', 'l', 0xe8, 0x07, 0x0a}
+
+	a := &archive50{}
+	f := &fileBlockHeader{}
+	if err := a.parseFileOwnerRecord(rec, f); err != nil {
+		t.Fatalf("parseFileOwnerRecord() error = %v", err)
+	}
+	if !f.HasUserName || f.UserName != "root" || !f.HasGroupName || f.GroupName != "wheel" ||
+		!f.HasUID || f.UID != 1000 || !f.HasGID || f.GID != 10 {
+		t.Errorf("parseFileOwnerRecord() = %+v", f.FileHeader)
+	}
+	if h, ok := (fileInfo{h: f}).Sys().(*FileHeader); !ok || h.UID != 1000 {
+		t.Errorf("fileInfo.Sys() = %v, want *FileHeader", (fileInfo{h: f}).Sys())
+	}
+
+	f = &fileBlockHeader{}
+	if err := a.parseFileOwnerRecord([]byte{file5OwnerGID, 0x0a}, f); err != nil {
+		t.Fatalf("parseFileOwnerRecord() error = %v", err)
+	}
+	if f.HasUserName || f.HasGroupName || f.HasUID || !f.HasGID || f.GID != 10 {
+		t.Errorf("parseFileOwnerRecord() GID only = %+v", f.FileHeader)
+	}
+
+	err := a.parseFileOwnerRecord([]byte{file5OwnerUserName, 8, 'r'}, &fileBlockHeader{})
+	if err != ErrCorruptFileHeader {
+		t.Errorf("parseFileOwnerRecord() short name error = %v, want %v", err, ErrCorruptFileHeader)
+	}
+}
+
+func TestParseArcBlock(t *testing.T) {
+	h := &blockHeader50{
+		htype: block5Arc,
+		data:  readBuf{arc5MultiVol | arc5VolNum | arc5Recovery | arc5Locked, 2},
+		extra: []extra{
+			{1, readBuf{locator5QuickOpen | locator5Recovery, 100, 0x80, 0x01}},
+			{2, readBuf{meta5Name | meta5Time | meta5UnixTime, 5, 't', 'e', 's', 't', 0, 0x00, 0xe1, 0xf5, 0x05}},
+			{99, readBuf{1, 2, 3}}, // unknown record type
+		},
+	}
+	a := &archive50{}
+	volnum, err := a.parseArcBlock(h)
+	if err != nil {
+		t.Fatalf("parseArcBlock() error = %v", err)
+	}
+	if volnum != 2 {
+		t.Errorf("parseArcBlock() volnum = %d, want 2", volnum)
+	}
+	want := ArchiveHeader{
+		Format:          ArchiveFormat50,
+		MultiVolume:     true,
+		VolumeNumber:    2,
+		Locked:          true,
+		RecoveryRecord:  true,
+		OriginalName:    "test",
+		CreationTime:    time.Unix(100000000, 0),
+		QuickOpenOffset: 100,
+		RecoveryOffset:  128,
+		ExtraRecords: []ExtraRecord{
+			{1, []byte{locator5QuickOpen | locator5Recovery, 100, 0x80, 0x01}},
+			{2, []byte{meta5Name | meta5Time | meta5UnixTime, 5, 't', 'e', 's', 't', 0, 0x00, 0xe1, 0xf5, 0x05}},
+			{99, []byte{1, 2, 3}},
+		},
+	}
+	got := a.archiveHeader()
+	if !got.CreationTime.Equal(want.CreationTime) {
+		t.Errorf("archiveHeader() CreationTime = %v, want %v", got.CreationTime, want.CreationTime)
+	}
+	got.CreationTime = want.CreationTime
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("archiveHeader() = %+v, want %+v", *got, want)
+	}
+
+	h = &blockHeader50{
+		htype: block5Arc,
+		data:  readBuf{arc5Solid},
+		extra: []extra{{2, readBuf{meta5Name, 10, 'a'}}},
+	}
+	if _, err = a.parseArcBlock(h); err != ErrCorruptBlockHeader {
+		t.Errorf("parseArcBlock() short name error = %v, want %v", err, ErrCorruptBlockHeader)
+	}
+	if got := a.archiveHeader(); !got.Solid || !got.FirstVolume || got.VolumeNumber != 0 {
+		t.Errorf("archiveHeader() = %+v, want solid first volume", *got)
+	}
+}
+
+func TestFileExtraRecords(t *testing.T) {
+	h := &blockHeader50{
+		htype: block5File,
+		data:  rar5FileData("a.txt", nil),
+		extra: []extra{
+			{4, readBuf{0, 3}},     // version
+			{99, readBuf{1, 2, 3}}, // unknown record type
+		},
+	}
+	a := &archive50{}
+	f, err := a.parseFileHeader(h)
+	if err != nil {
+		t.Fatalf("parseFileHeader() error = %v", err)
+	}
+	want := []ExtraRecord{{4, []byte{0, 3}}, {99, []byte{1, 2, 3}}}
+	if f.Version != 3 || !reflect.DeepEqual(f.ExtraRecords, want) {
+		t.Errorf("parseFileHeader() version = %d, extra records = %v, want 3, %v", f.Version, f.ExtraRecords, want)
+	}
+}
+
+// quickOpenArchive returns a RAR 5 archive containing files with the given names
+// and contents and a quick open block caching all of the file headers.
+// The returned offsets are the positions of each file header in the archive.
+func quickOpenArchive(names []string, contents [][]byte) ([]byte, []int) {
+	var blocks [][]byte
+	for i, name := range names {
+		blocks = append(blocks, rar5Block(block5File, 0, rar5FileData(name, contents[i]), nil, contents[i]))
+	}
+	for qoff := 0; ; {
+		var loc []byte
+		loc = binary.AppendUvarint(loc, 1) // locator record
+		loc = binary.AppendUvarint(loc, locator5QuickOpen)
+		loc = binary.AppendUvarint(loc, uint64(qoff))
+		extra := append(binary.AppendUvarint(nil, uint64(len(loc))), loc...)
+		arc := []byte(sig50)
+		arc = append(arc, rar5Block(block5Arc, 0, []byte{0}, extra, nil)...)
+		var offs []int
+		for _, b := range blocks {
+			offs = append(offs, len(arc))
+			arc = append(arc, b...)
+		}
+		if len(arc)-len(sig50) != qoff {
+			qoff = len(arc) - len(sig50)
+			continue
+		}
+		var data []byte
+		for i, b := range blocks {
+			hsize := len(b) - len(contents[i])
+			var body []byte
+			body = binary.AppendUvarint(body, 0) // flags
+			body = binary.AppendUvarint(body, uint64(len(arc)-offs[i]))
+			body = binary.AppendUvarint(body, uint64(hsize))
+			body = append(body, b[:hsize]...)
+			rec := append(binary.AppendUvarint(nil, uint64(len(body))), body...)
+			data = binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(rec))
+			data = append(data, rec...)
+		}
+		arc = append(arc, rar5Block(block5Service, 0, rar5FileData(serviceQuickOpen, data), nil, data)...)
+		arc = append(arc, rar5Block(block5End, 0, []byte{0}, nil, nil)...)
+		return arc, offs
+	}
+}
+
+func TestQuickOpen(t *testing.T) {
+	names := []string{"a.txt", "b.txt", "c.txt"}
+	contents := [][]byte{[]byte("first file"), []byte("second file"), []byte("third file")}
+	arc, offs := quickOpenArchive(names, contents)
+
+	// Headers in the volume are damaged, so they can only be read from the cache.
+	cached := bytes.Clone(arc)
+	for _, off := range offs {
+		cached[off] ^= 0xff
+	}
+	// Quick open record is damaged, so headers are read from the volume.
+	badqo := bytes.Clone(arc)
+	badqo[len(badqo)-20] ^= 0xff
+	// Both are damaged.
+	bad := bytes.Clone(cached)
+	bad[len(bad)-20] ^= 0xff
+
+	fsys := fstest.MapFS{
+		"cached.rar": {Data: cached},
+		"badqo.rar":  {Data: badqo},
+		"bad.rar":    {Data: bad},
+	}
+	for _, name := range []string{"cached.rar", "badqo.rar"} {
+		rfs, err := OpenFS(name, FileSystem(fsys))
+		if err != nil {
+			t.Fatalf("OpenFS(%s) error = %v", name, err)
+		}
+		for i, fname := range names {
+			b, err := rfs.ReadFile(fname)
+			if err != nil || !bytes.Equal(b, contents[i]) {
+				t.Errorf("%s: ReadFile(%s) = %q, %v, want %q", name, fname, b, err, contents[i])
+			}
+		}
+		infos, err := ListArchiveInfo(name, FileSystem(fsys))
+		if err != nil || len(infos) != len(names) {
+			t.Fatalf("%s: ListArchiveInfo() = %d files, %v", name, len(infos), err)
+		}
+		for i, info := range infos {
+			off := info.Parts[0].DataOffset
+			if info.Name != names[i] || !bytes.HasPrefix(arc[off:], contents[i]) {
+				t.Errorf("%s: file %d = %s at offset %d", name, i, info.Name, off)
+			}
+		}
+	}
+	if _, err := OpenFS("bad.rar", FileSystem(fsys)); err != ErrBadHeaderCRC {
+		t.Errorf("OpenFS(bad.rar) error = %v, want %v", err, ErrBadHeaderCRC)
+	}
+}
+
+// seekCountFS opens files that only support Read and Seek, counting the
+// number of Seek calls made on them.
+type seekCountFS struct {
+	fs.FS
+	seeks int
+}
+
+type seekCountFile struct {
+	fs.File
+	fsys *seekCountFS
+}
+
+func (f seekCountFile) Seek(offset int64, whence int) (int64, error) {
+	f.fsys.seeks++
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+func (fsys *seekCountFS) Open(name string) (fs.File, error) {
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return seekCountFile{File: f, fsys: fsys}, nil
+}
+
+func TestQuickOpenSeeks(t *testing.T) {
+	var names []string
+	var contents [][]byte
+	for i := 0; i < 20; i++ {
+		names = append(names, fmt.Sprintf("file%02d.txt", i))
+		contents = append(contents, bytes.Repeat([]byte{byte('a' + i)}, 2*defaultBufSize))
+	}
+	arc, _ := quickOpenArchive(names, contents)
+	badqo := bytes.Clone(arc)
+	badqo[len(badqo)-20] ^= 0xff
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"cached.rar", arc},
+		{"badqo.rar", badqo},
+	} {
+		fsys := &seekCountFS{FS: fstest.MapFS{tt.name: {Data: tt.data}}}
+		infos, err := ListArchiveInfo(tt.name, FileSystem(fsys))
+		if err != nil || len(infos) != len(names) {
+			t.Fatalf("%s: ListArchiveInfo() = %d files, %v", tt.name, len(infos), err)
+		}
+		cached := tt.name == "cached.rar"
+		if cached && fsys.seeks >= len(names) {
+			t.Errorf("%s: ListArchiveInfo() made %d seeks, want fewer than %d", tt.name, fsys.seeks, len(names))
+		} else if !cached && fsys.seeks < len(names) {
+			t.Errorf("%s: ListArchiveInfo() made %d seeks, want at least %d", tt.name, fsys.seeks, len(names))
+		}
+	}
+}
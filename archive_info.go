@@ -15,7 +15,7 @@ type FilePartInfo struct {
 	UnpackedSize      int64  `json:"unpackedSize"`            // Total unpacked size of the complete file
 	Stored            bool   `json:"stored"`                  // True if data is stored (not compressed)
 	Compressed        bool   `json:"compressed"`              // True if data is compressed
-	CompressionMethod string `json:"compressionMethod"`       // Compression method used (stored, rar2.0, rar2.9, rar5.0, rar7.0)
+	CompressionMethod string `json:"compressionMethod"`       // Compression method used (stored, rar1.5, rar2.0, rar2.9, rar5.0, rar7.0)
 	Encrypted         bool   `json:"encrypted"`               // True if this part is encrypted
 	Salt          []byte `json:"salt,omitempty"`          // Salt for key derivation (only if encrypted and password provided)
 	AesKey        []byte `json:"aesKey,omitempty"`        // AES-256 key (32 bytes, only if encrypted and password provided)
@@ -32,7 +32,7 @@ type ArchiveFileInfo struct {
 	AnyEncrypted      bool           `json:"anyEncrypted"`      // True if any part is encrypted
 	AllStored         bool           `json:"allStored"`         // True if all parts are stored (not compressed)
 	Compressed        bool           `json:"compressed"`        // True if file is compressed
-	CompressionMethod string         `json:"compressionMethod"` // Compression method used (stored, rar2.0, rar2.9, rar5.0, rar7.0)
+	CompressionMethod string         `json:"compressionMethod"` // Compression method used (stored, rar1.5, rar2.0, rar2.9, rar5.0, rar7.0)
 }
 
 // compressionMethodName returns a human-readable name for the compression method
@@ -41,13 +41,15 @@ func compressionMethodName(decVer int) string {
 	switch decVer {
 	case 0:
 		return "stored"
-	case 1:
+	case decode15Ver:
+		return "rar1.5"
+	case decode20Ver:
 		return "rar2.0"
-	case 2:
+	case decode29Ver:
 		return "rar2.9"
-	case 3:
+	case decode50Ver:
 		return "rar5.0"
-	case 4:
+	case decode70Ver:
 		return "rar7.0"
 	default:
 		return "unknown"
@@ -65,6 +67,9 @@ func compressionMethodName(decVer int) string {
 //
 // Parallel processing is used by default for multi-volume archives. Use
 // MaxConcurrentVolumes(n) to limit concurrency if needed.
+//
+// RAR 5 volumes containing quick open data have their file headers read from
+// the cached copies instead of from each block in the volume.
 func ListArchiveInfo(name string, opts ...Option) ([]ArchiveFileInfo, error) {
 	// Prepend parallel read and a small buffer for metadata-only reads. If the caller
 	// explicitly passes these options, they will be applied after and take precedence.
@@ -309,6 +314,16 @@ func (it *ArchiveIterator) Err() error {
 	return it.err
 }
 
+// ArchiveHeader returns the main archive header of the first volume opened.
+func (it *ArchiveIterator) ArchiveHeader() *ArchiveHeader {
+	return it.vm.archiveHeader()
+}
+
+// Comment returns the archive comment, or an empty string if the archive has none.
+func (it *ArchiveIterator) Comment() (string, error) {
+	return it.vm.comment()
+}
+
 // Close releases resources associated with the iterator.
 // It should be called when iteration is complete.
 // Close is safe to call multiple times.
@@ -0,0 +1,185 @@
+package rardecode
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+const (
+	blake2sBlockSize = 64
+	blake2sSize      = 32
+	blake2spLeaves   = 8
+)
+
+var blake2sIV = [8]uint32{
+	0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a,
+	0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19,
+}
+
+var blake2sSigma = [10][16]byte{
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	{14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3},
+	{11, 8, 12, 0, 5, 2, 15, 13, 10, 14, 3, 6, 7, 1, 9, 4},
+	{7, 9, 3, 1, 13, 12, 11, 14, 2, 6, 5, 10, 4, 0, 15, 8},
+	{9, 0, 5, 7, 2, 4, 10, 15, 14, 1, 11, 12, 6, 8, 3, 13},
+	{2, 12, 6, 10, 0, 11, 8, 3, 4, 13, 7, 5, 15, 14, 1, 9},
+	{12, 5, 1, 15, 14, 13, 4, 10, 0, 7, 6, 3, 9, 2, 8, 11},
+	{13, 11, 7, 14, 12, 1, 3, 9, 5, 0, 15, 4, 8, 6, 2, 10},
+	{6, 15, 14, 9, 11, 3, 0, 8, 12, 2, 13, 7, 1, 4, 10, 5},
+	{10, 2, 8, 4, 7, 6, 1, 5, 15, 11, 9, 14, 3, 12, 13, 0},
+}
+
+// blake2s is a BLAKE2s hash node configured with the tree parameters
+// used by BLAKE2sp.
+type blake2s struct {
+	h        [8]uint32
+	t        uint64
+	x        [blake2sBlockSize]byte
+	nx       int
+	init     [8]uint32 // initial state, used by Reset
+	lastNode bool      // node is the last in its level of the tree
+}
+
+func (d *blake2s) Reset() {
+	d.h = d.init
+	d.t = 0
+	d.nx = 0
+}
+
+func (d *blake2s) Size() int      { return blake2sSize }
+func (d *blake2s) BlockSize() int { return blake2sBlockSize }
+
+func (d *blake2s) compress(b []byte, final bool) {
+	var m [16]uint32
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+	v := [16]uint32{
+		d.h[0], d.h[1], d.h[2], d.h[3], d.h[4], d.h[5], d.h[6], d.h[7],
+		blake2sIV[0], blake2sIV[1], blake2sIV[2], blake2sIV[3],
+		blake2sIV[4] ^ uint32(d.t), blake2sIV[5] ^ uint32(d.t>>32),
+		blake2sIV[6], blake2sIV[7],
+	}
+	if final {
+		v[14] = ^v[14]
+		if d.lastNode {
+			v[15] = ^v[15]
+		}
+	}
+	g := func(a, b, c, d int, x, y uint32) {
+		v[a] += v[b] + x
+		v[d] = bits.RotateLeft32(v[d]^v[a], -16)
+		v[c] += v[d]
+		v[b] = bits.RotateLeft32(v[b]^v[c], -12)
+		v[a] += v[b] + y
+		v[d] = bits.RotateLeft32(v[d]^v[a], -8)
+		v[c] += v[d]
+		v[b] = bits.RotateLeft32(v[b]^v[c], -7)
+	}
+	for _, s := range blake2sSigma {
+		g(0, 4, 8, 12, m[s[0]], m[s[1]])
+		g(1, 5, 9, 13, m[s[2]], m[s[3]])
+		g(2, 6, 10, 14, m[s[4]], m[s[5]])
+		g(3, 7, 11, 15, m[s[6]], m[s[7]])
+		g(0, 5, 10, 15, m[s[8]], m[s[9]])
+		g(1, 6, 11, 12, m[s[10]], m[s[11]])
+		g(2, 7, 8, 13, m[s[12]], m[s[13]])
+		g(3, 4, 9, 14, m[s[14]], m[s[15]])
+	}
+	for i := range d.h {
+		d.h[i] ^= v[i] ^ v[i+8]
+	}
+}
+
+func (d *blake2s) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if d.nx == blake2sBlockSize {
+			// only compress a full block once more data is known to follow,
+			// as the final block must be compressed with the final flag set
+			d.t += blake2sBlockSize
+			d.compress(d.x[:], false)
+			d.nx = 0
+		}
+		l := copy(d.x[d.nx:], p)
+		d.nx += l
+		p = p[l:]
+	}
+	return n, nil
+}
+
+func (d *blake2s) Sum(b []byte) []byte {
+	c := *d
+	clear(c.x[c.nx:])
+	c.t += uint64(c.nx)
+	c.compress(c.x[:], true)
+	var sum [blake2sSize]byte
+	for i, v := range c.h {
+		binary.LittleEndian.PutUint32(sum[i*4:], v)
+	}
+	return append(b, sum[:]...)
+}
+
+// newBlake2spNode returns a BLAKE2s node at the given offset and depth of a
+// BLAKE2sp hash tree.
+func newBlake2spNode(offset, depth int, lastNode bool) *blake2s {
+	d := &blake2s{lastNode: lastNode}
+	d.init = blake2sIV
+	d.init[0] ^= blake2sSize | blake2spLeaves<<16 | 2<<24 // digest length, fanout, max depth
+	d.init[2] ^= uint32(offset)
+	d.init[3] ^= uint32(depth)<<16 | blake2sSize<<24 // node depth, inner length
+	d.Reset()
+	return d
+}
+
+// blake2sp implements the BLAKE2sp hash used for RAR 5 file checksums.
+// Input is split into 64 byte blocks which are distributed round robin to
+// 8 BLAKE2s leaf nodes, whose digests are then hashed by a root node.
+type blake2sp struct {
+	leaves [blake2spLeaves]*blake2s
+	n      uint64 // total bytes written
+}
+
+func (d *blake2sp) Reset() {
+	for _, l := range d.leaves {
+		l.Reset()
+	}
+	d.n = 0
+}
+
+func (d *blake2sp) Size() int      { return blake2sSize }
+func (d *blake2sp) BlockSize() int { return blake2sBlockSize * blake2spLeaves }
+
+func (d *blake2sp) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := d.n / blake2sBlockSize % blake2spLeaves
+		l := blake2sBlockSize - int(d.n%blake2sBlockSize)
+		if l > len(p) {
+			l = len(p)
+		}
+		_, _ = d.leaves[i].Write(p[:l])
+		d.n += uint64(l)
+		p = p[l:]
+	}
+	return n, nil
+}
+
+func (d *blake2sp) Sum(b []byte) []byte {
+	root := newBlake2spNode(0, 1, true)
+	var sum []byte
+	for _, l := range d.leaves {
+		sum = l.Sum(sum[:0])
+		_, _ = root.Write(sum)
+	}
+	return root.Sum(b)
+}
+
+func newBlake2sp() hash.Hash {
+	d := new(blake2sp)
+	for i := range d.leaves {
+		d.leaves[i] = newBlake2spNode(i, 0, i == blake2spLeaves-1)
+	}
+	return d
+}
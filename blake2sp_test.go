@@ -0,0 +1,121 @@
+package rardecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestBlake2sp(t *testing.T) {
+	tests := []struct {
+		size int
+		sum  string
+	}{
+		{0, "dd0e891776933f43c7d032b08a917e25741f8aa9a12c12e1cac8801500f2ca4f"},
+		{1, "a6b9eecc25227ad788c99d3f236debc8da408849e9a5178978727a81457f7239"},
+		{3, "ed14413b40da689f1f7fed2b08dff45b8092db5ec2c3610e02724d202f423c46"},
+		{64, "52603b6cbfad4966cb044cb267568385cf35f21e6c45cf30aed19832cb51e9f5"},
+		{65, "fff24d3cc729d395daf978b0157306cb495797e6c8dca1731d2f6f81b849baae"},
+		{511, "8e1e8ee1ffa0a01028fff3bff0ae9df2565a82e55a04e9541bb78b9c4778336f"},
+		{512, "8d9e357863298dd8364b7caf4234317f8a49f180d788b7abffb521925f1e1ff1"},
+		{513, "8a4bc3330497e681f15daf24fc496044a1c32bf0a837a210399e1ae4af7e92be"},
+		{1000, "611f1af6610cdaf674ec2c9178f6376ebe234ef50998a3be3f1fa698fb779274"},
+		{4096, "dd02c617ddc87d204cbcb5795b637368467fa516710f880e9c782b00b0dca78c"},
+		{5000, "654900a5431ad42ce22176aab694c795fd0fa188b2677f70849e6ba19dd2202d"},
+	}
+	for _, tt := range tests {
+		data := make([]byte, tt.size)
+		for i := range data {
+			data[i] = byte(i % 251)
+		}
+		h := newBlake2sp()
+		_, _ = h.Write(data)
+		if got := hex.EncodeToString(h.Sum(nil)); got != tt.sum {
+			t.Errorf("blake2sp(%d bytes) = %s, want %s", tt.size, got, tt.sum)
+		}
+
+		// write in odd sized pieces to check block handling
+		h.Reset()
+		for b := data; len(b) > 0; {
+			n := min(len(b), 37)
+			_, _ = h.Write(b[:n])
+			b = b[n:]
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != tt.sum {
+			t.Errorf("blake2sp(%d bytes) split writes = %s, want %s", tt.size, got, tt.sum)
+		}
+	}
+}
+
+func TestParseFileHashRecord(t *testing.T) {
+	sum := make([]byte, blake2sSize)
+	for i := range sum {
+		sum[i] = byte(i)
+	}
+	a := &archive50{}
+	f := &fileBlockHeader{first: true, last: true}
+	err := a.parseFileHashRecord(append([]byte{hash5Blake2sp}, sum...), f)
+	if err != nil {
+		t.Fatalf("parseFileHashRecord() error = %v", err)
+	}
+	if f.HashType != HashBLAKE2sp || hex.EncodeToString(f.Hash) != hex.EncodeToString(sum) {
+		t.Errorf("parseFileHashRecord() HashType = %d, Hash = %x", f.HashType, f.Hash)
+	}
+	if f.hash == nil {
+		t.Error("parseFileHashRecord() did not set hash function for first block")
+	}
+
+	err = a.parseFileHashRecord([]byte{hash5Blake2sp, 1, 2, 3}, &fileBlockHeader{})
+	if err != ErrCorruptFileHeader {
+		t.Errorf("parseFileHashRecord() short record error = %v, want %v", err, ErrCorruptFileHeader)
+	}
+}
+
+func TestBlake2spFileChecksum(t *testing.T) {
+	contents := []byte("contents checked with a BLAKE2sp hash")
+	h := newBlake2sp()
+	_, _ = h.Write(contents)
+	good := h.Sum(nil)
+	bad := slices.Clone(good)
+	bad[0] ^= 1
+
+	file := func(name string, sum []byte) []byte {
+		var b []byte
+		b = binary.AppendUvarint(b, 0) // file flags, no CRC32
+		b = binary.AppendUvarint(b, uint64(len(contents)))
+		b = binary.AppendUvarint(b, 0) // attributes
+		b = binary.AppendUvarint(b, 0) // compression info
+		b = binary.AppendUvarint(b, 1) // host os
+		b = binary.AppendUvarint(b, uint64(len(name)))
+		b = append(b, name...)
+		rec := append([]byte{2, hash5Blake2sp}, sum...)
+		extra := append(binary.AppendUvarint(nil, uint64(len(rec))), rec...)
+		return rar5Block(block5File, 0, b, extra, contents)
+	}
+	arc := []byte(sig50)
+	arc = append(arc, rar5Block(block5Arc, 0, []byte{0}, nil, nil)...)
+	arc = append(arc, file("good.txt", good)...)
+	arc = append(arc, file("bad.txt", bad)...)
+	arc = append(arc, rar5Block(block5End, 0, []byte{0}, nil, nil)...)
+
+	r, err := NewReader(bytes.NewReader(arc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []error{nil, ErrBadFileChecksum} {
+		fh, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next error = %v", err)
+		}
+		if fh.HashType != HashBLAKE2sp {
+			t.Errorf("%s HashType = %d, want %d", fh.Name, fh.HashType, HashBLAKE2sp)
+		}
+		b, err := io.ReadAll(r)
+		if err != want || !bytes.Equal(b, contents) {
+			t.Errorf("read %s = %q, %v, want error %v", fh.Name, b, err, want)
+		}
+	}
+}
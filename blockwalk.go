@@ -0,0 +1,168 @@
+package rardecode
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+)
+
+var (
+	ErrBlockWalkUnsupported = errors.New("rardecode: RAR 1.3 - 1.4 archives have no blocks to walk")
+)
+
+// Block describes a raw block in a RAR archive volume.
+type Block struct {
+	Format       int           // archive format (ArchiveFormat15 or ArchiveFormat50)
+	Volume       int           // volume number starting from 0
+	Type         uint64        // block type
+	Flags        uint64        // block flags
+	HeaderOffset int64         // offset of the block header in the volume file
+	HeaderSize   int64         // size of the block header in the volume file, including any encryption salt, IV and padding
+	DataOffset   int64         // offset of the block data in the volume file
+	DataSize     int64         // size of the block data
+	CRCValid     bool          // block header checksum is correct
+	Header       []byte        // block header starting with its checksum, decrypted if headers are encrypted
+	Extra        []ExtraRecord // extra records of the block header (RAR 5 only)
+}
+
+// rawBlockReader reads the raw block headers of an archive volume.
+type rawBlockReader interface {
+	readRawBlock(br *bufVolumeReader) (*Block, error)
+	useOldNaming() bool
+	archiveHeader() *ArchiveHeader
+}
+
+// BlockWalker returns every block in each volume of a RAR archive, including
+// the service, comment, end and unknown blocks skipped when reading files.
+// Blocks with a bad header checksum are returned with CRCValid unset, rather
+// than ending the walk. It is intended for inspecting damaged archives.
+type BlockWalker struct {
+	vm  *volumeManager
+	f   fs.File
+	br  *bufVolumeReader
+	arc rawBlockReader
+	vol int   // current volume number
+	n   int64 // data left to skip in the current block
+	err error // error to return from the next call to Next
+}
+
+// open opens the volume file volnum, positioning it after the RAR signature.
+func (w *BlockWalker) open(volnum int) error {
+	f, err := w.vm.openVolumeFile(volnum)
+	if err != nil {
+		return err
+	}
+	br, err := newBufVolumeReader(f, w.vm.opt.bsize)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	var arc rawBlockReader
+	switch br.ver {
+	case archiveVersion15:
+		arc = newArchive15(w.vm.opt.pass)
+	case archiveVersion50:
+		arc = newArchive50(w.vm.opt.pass)
+	case archiveVersion14:
+		err = ErrBlockWalkUnsupported
+	default:
+		err = ErrUnknownVersion
+	}
+	if err == nil && w.arc != nil && br.ver != w.br.ver {
+		err = ErrVerMismatch
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if w.f != nil {
+		w.f.Close()
+	}
+	w.f, w.br, w.arc = f, br, arc
+	w.vol = volnum
+	w.n = 0
+	return nil
+}
+
+// nextVolume opens the next volume of a multi-volume archive.
+// io.EOF is returned if there are no more volumes.
+func (w *BlockWalker) nextVolume() error {
+	hdr := w.arc.archiveHeader()
+	if hdr == nil || !hdr.MultiVolume {
+		return io.EOF
+	}
+	if w.vol == 0 {
+		w.vm.old = w.arc.useOldNaming()
+	}
+	err := w.open(w.vol + 1)
+	if errors.Is(err, fs.ErrNotExist) {
+		return io.EOF
+	}
+	return err
+}
+
+// Next returns the next block in the archive.
+// io.EOF is returned when there are no more blocks in the last volume.
+func (w *BlockWalker) Next() (*Block, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	if w.n > 0 {
+		err := w.br.Discard(w.n)
+		w.n = 0
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			w.err = err
+			return nil, err
+		}
+	}
+	for {
+		b, err := w.arc.readRawBlock(w.br)
+		if b != nil {
+			// an error for a returned block prevents reading any further blocks
+			w.err = err
+			b.Volume = w.vol
+			w.n = b.DataSize
+			return b, nil
+		}
+		if err == io.EOF {
+			err = w.nextVolume()
+			if err == nil {
+				continue
+			}
+		}
+		w.err = err
+		return nil, err
+	}
+}
+
+// Volumes returns the volume filenames that have been walked so far.
+func (w *BlockWalker) Volumes() []string {
+	return w.vm.Files()
+}
+
+// Close closes the current archive volume.
+func (w *BlockWalker) Close() error {
+	return w.f.Close()
+}
+
+// OpenBlockWalker opens the RAR archive specified by name and returns a BlockWalker
+// positioned before the first block. Header encrypted archives require the Password option.
+func OpenBlockWalker(name string, opts ...Option) (*BlockWalker, error) {
+	dir, file := filepath.Split(name)
+	w := &BlockWalker{
+		vm: &volumeManager{
+			dir:   dir,
+			files: []string{file},
+			opt:   getOptions(opts),
+		},
+	}
+	err := w.open(0)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
@@ -0,0 +1,140 @@
+package rardecode
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"io"
+	"testing"
+	"testing/fstest"
+)
+
+type walkedBlock struct {
+	volume   int
+	btype    uint64
+	offset   int64
+	dataSize int64
+	crcValid bool
+}
+
+// walkBlocks returns the blocks of the archive name and the error that ended the walk.
+func walkBlocks(t *testing.T, name string, opts ...Option) ([]walkedBlock, error) {
+	t.Helper()
+	w, err := OpenBlockWalker(name, opts...)
+	if err != nil {
+		t.Fatalf("OpenBlockWalker(%s) error = %v", name, err)
+	}
+	defer w.Close()
+	var blocks []walkedBlock
+	for {
+		b, err := w.Next()
+		if err != nil {
+			return blocks, err
+		}
+		if b.DataOffset != b.HeaderOffset+b.HeaderSize {
+			t.Errorf("%s block %d data offset = %d, want %d", name, len(blocks), b.DataOffset, b.HeaderOffset+b.HeaderSize)
+		}
+		blocks = append(blocks, walkedBlock{b.Volume, b.Type, b.HeaderOffset, b.DataSize, b.CRCValid})
+	}
+}
+
+func TestBlockWalker(t *testing.T) {
+	contents := []byte("block walker")
+
+	// RAR 3.x archive with a damaged service block header
+	arcBlock := rar15Block(blockArc, 0, make([]byte, 6), 0)
+	fileBlock := rar15Block(blockFile, blockHasData, rar15File("a.txt", contents), 0)
+	svcBlock := rar15Block(blockService, blockHasData, rar15File(serviceComment, contents), 0)
+	svcBlock[0] ^= 0xff
+	arc30 := []byte(sig15)
+	arc30 = append(arc30, arcBlock...)
+	arc30 = append(arc30, fileBlock...)
+	arc30 = append(arc30, contents...)
+	arc30 = append(arc30, svcBlock...)
+	arc30 = append(arc30, contents...)
+	arc30 = append(arc30, rar15Block(blockEnd, 0, nil, 0)...)
+
+	// RAR 5 archive with encrypted headers
+	salt := make([]byte, 16)
+	key := calcKeys50([]byte("password"), salt, 1)[0]
+	encrypt := func(hdr []byte) []byte {
+		iv := make([]byte, 16)
+		iv[0] = byte(len(hdr))
+		hdr = append(hdr, make([]byte, -len(hdr)&15)...)
+		block, _ := aes.NewCipher(key)
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(hdr, hdr)
+		return append(iv, hdr...)
+	}
+	fileHeader := rar5Block(block5File, 0, rar5FileData("a.txt", contents), nil, contents)
+	fileHeader = fileHeader[:len(fileHeader)-len(contents)]
+	encArc := []byte(sig50)
+	encArc = append(encArc, rar5Block(block5Encrypt, 0, append([]byte{0, 0, 0}, salt...), nil, nil)...)
+	arcOff := int64(len(encArc))
+	encArc = append(encArc, encrypt(rar5Block(block5Arc, 0, []byte{0}, nil, nil))...)
+	fileOff := int64(len(encArc))
+	encArc = append(encArc, encrypt(fileHeader)...)
+	encArc = append(encArc, contents...)
+	endOff := int64(len(encArc))
+	encArc = append(encArc, encrypt(rar5Block(block5End, 0, []byte{0}, nil, nil))...)
+
+	fsys := fstest.MapFS{
+		"arc30.rar": {Data: arc30},
+		"enc.rar":   {Data: encArc},
+	}
+	vols := multiVolumeArchive(contents, 2)
+	fsys["arc.part1.rar"] = &fstest.MapFile{Data: vols[0]}
+	fsys["arc.part2.rar"] = &fstest.MapFile{Data: vols[1]}
+
+	off := int64(len(sig15))
+	fileOff30 := off + int64(len(arcBlock))
+	svcOff := fileOff30 + int64(len(fileBlock)+len(contents))
+	endOff30 := svcOff + int64(len(svcBlock)+len(contents))
+	encOff := int64(len(sig50))
+	volArc := int64(len(sig50))
+	vol0Arc := rar5Block(block5Arc, 0, []byte{arc5MultiVol}, nil, nil)
+	vol1Arc := rar5Block(block5Arc, 0, []byte{arc5MultiVol | arc5VolNum, 1}, nil, nil)
+	tests := []struct {
+		name   string
+		opts   []Option
+		blocks []walkedBlock
+		err    error
+	}{
+		{"arc30.rar", nil, []walkedBlock{
+			{0, blockArc, off, 0, true},
+			{0, blockFile, fileOff30, int64(len(contents)), true},
+			{0, blockService, svcOff, int64(len(contents)), false},
+			{0, blockEnd, endOff30, 0, true},
+		}, io.EOF},
+		{"enc.rar", []Option{Password("password")}, []walkedBlock{
+			{0, block5Encrypt, encOff, 0, true},
+			{0, block5Arc, arcOff, 0, true},
+			{0, block5File, fileOff, int64(len(contents)), true},
+			{0, block5End, endOff, 0, true},
+		}, io.EOF},
+		{"enc.rar", nil, []walkedBlock{
+			{0, block5Encrypt, encOff, 0, true},
+		}, ErrArchiveEncrypted},
+		{"arc.part1.rar", nil, []walkedBlock{
+			{0, block5Arc, volArc, 0, true},
+			{0, block5File, volArc + int64(len(vol0Arc)), 6, true},
+			{0, block5End, int64(len(vols[0]) - 8), 0, true},
+			{1, block5Arc, volArc, 0, true},
+			{1, block5File, volArc + int64(len(vol1Arc)), 6, true},
+			{1, block5End, int64(len(vols[1]) - 8), 0, true},
+		}, io.EOF},
+	}
+	for _, tt := range tests {
+		blocks, err := walkBlocks(t, tt.name, append(tt.opts, FileSystem(fsys))...)
+		if err != tt.err {
+			t.Errorf("%s: walk error = %v, want %v", tt.name, err, tt.err)
+		}
+		if len(blocks) != len(tt.blocks) {
+			t.Errorf("%s: walked %d blocks, want %d: %+v", tt.name, len(blocks), len(tt.blocks), blocks)
+			continue
+		}
+		for i, b := range blocks {
+			if b != tt.blocks[i] {
+				t.Errorf("%s: block %d = %+v, want %+v", tt.name, i, b, tt.blocks[i])
+			}
+		}
+	}
+}
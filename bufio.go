@@ -33,6 +33,8 @@ type bufVolumeReader struct {
 	off int64
 	err error
 	ver int
+
+	seekPending bool // sr must be moved to off before the next read
 }
 
 func (br *bufVolumeReader) readErr() error {
@@ -59,6 +61,15 @@ func (br *bufVolumeReader) fill() error {
 		}
 		return err
 	}
+	if br.seekPending {
+		// seeks are deferred until data is needed, so skipping over
+		// blocks that are never read costs no system calls
+		_, err := br.sr.Seek(br.off, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		br.seekPending = false
+	}
 	// Streaming fallback (non-ReaderAt sources: pipes, network, bytes.Reader etc.)
 	for i := 0; i < maxEmptyReads; i++ {
 		br.n, br.err = br.r.Read(br.buf)
@@ -98,13 +109,10 @@ func (br *bufVolumeReader) seek(offset int64) error {
 		br.i += int(diff)
 		return nil
 	}
-	_, err := br.sr.Seek(offset, io.SeekStart)
-	if err != nil {
-		return err
-	}
 	br.i = 0
 	br.n = 0
 	br.off = offset
+	br.seekPending = true
 	return nil
 }
 
@@ -154,14 +162,11 @@ func (br *bufVolumeReader) Discard(n int64) error {
 		return nil
 	}
 
-	// Optimization: prefer seeking for large discards
+	// Optimization: prefer seeking for large discards, deferred until the next read.
 	// This is especially beneficial when skipping large files in metadata-only mode
 	if br.sr != nil {
-		_, err := br.sr.Seek(n, io.SeekCurrent)
-		if err != nil {
-			return err
-		}
 		br.off += n
+		br.seekPending = true
 		return nil
 	}
 
@@ -258,6 +263,12 @@ func (br *bufVolumeReader) findSig() (int, error) {
 				return 0, err
 			}
 		}
+		if br.off == 0 && bytes.HasPrefix(br.buf[br.i:br.n], []byte(sig14)) {
+			// RAR 1.4 archives are only recognised at the start of the file
+			br.i += len(sig14)
+			br.off += int64(len(sig14))
+			return archiveVersion14, nil
+		}
 		if !bytes.HasPrefix(br.buf[br.i:br.n], []byte(sigPrefix)) {
 			br.i++
 			br.off++
@@ -288,12 +299,18 @@ func (br *bufVolumeReader) Reset(r io.Reader) error {
 	br.i = 0
 	br.n = 0
 	br.off = 0
+	br.seekPending = false
 
 	// Fast path: when io.ReaderAt is available, try reading signature at offset 0 directly.
 	// This avoids the up-to-1MB linear scan done by findSig() for standard archives.
 	if br.ra != nil {
 		var sigBuf [sigPrefixLen + 2]byte
 		n, _ := br.ra.ReadAt(sigBuf[:], 0)
+		if bytes.HasPrefix(sigBuf[:n], []byte(sig14)) {
+			br.off = int64(len(sig14))
+			br.ver = archiveVersion14
+			return nil
+		}
 		if n >= sigPrefixLen+1 && bytes.HasPrefix(sigBuf[:n], []byte(sigPrefix)) {
 			ver := int(sigBuf[sigPrefixLen])
 			if ver == 0 {
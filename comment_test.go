@@ -0,0 +1,197 @@
+package rardecode
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+	"testing/fstest"
+)
+
+const sig15 = "Rar!\x1a\x07\x00"
+
+// rar5Block returns a RAR 5 block with the given header fields, extra area and data area.
+func rar5Block(htype, flags uint64, data, extra, area []byte) []byte {
+	var b []byte
+	b = binary.AppendUvarint(b, htype)
+	if len(extra) > 0 {
+		flags |= block5HasExtra
+	}
+	if len(area) > 0 {
+		flags |= block5HasData
+	}
+	b = binary.AppendUvarint(b, flags)
+	if len(extra) > 0 {
+		b = binary.AppendUvarint(b, uint64(len(extra)))
+	}
+	if len(area) > 0 {
+		b = binary.AppendUvarint(b, uint64(len(area)))
+	}
+	b = append(b, data...)
+	b = append(b, extra...)
+	hdr := binary.AppendUvarint(nil, uint64(len(b)))
+	hdr = append(hdr, b...)
+	out := binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(hdr))
+	out = append(out, hdr...)
+	return append(out, area...)
+}
+
+// rar5FileData returns the data fields of a stored RAR 5 file or service header.
+func rar5FileData(name string, contents []byte) []byte {
+	var b []byte
+	b = binary.AppendUvarint(b, file5HasCRC32)
+	b = binary.AppendUvarint(b, uint64(len(contents)))
+	b = binary.AppendUvarint(b, 0) // attributes
+	b = binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(contents))
+	b = binary.AppendUvarint(b, 0) // compression info
+	b = binary.AppendUvarint(b, 1) // host os
+	b = binary.AppendUvarint(b, uint64(len(name)))
+	return append(b, name...)
+}
+
+// rar15Block returns a RAR 1.5 block with the given header type, flags and header data.
+// The header CRC is calculated over the first crcSize bytes, or the whole header if zero.
+func rar15Block(htype byte, flags uint16, data []byte, crcSize int) []byte {
+	b := make([]byte, 7, 7+len(data))
+	b[2] = htype
+	binary.LittleEndian.PutUint16(b[3:], flags)
+	binary.LittleEndian.PutUint16(b[5:], uint16(7+len(data)))
+	b = append(b, data...)
+	if crcSize == 0 {
+		crcSize = len(b)
+	}
+	binary.LittleEndian.PutUint16(b, uint16(crc32.ChecksumIEEE(b[2:crcSize])))
+	return b
+}
+
+func TestComment(t *testing.T) {
+	const comment = "Greetings from the archive comment."
+	cmt := []byte(comment)
+	crc := crc32.ChecksumIEEE(cmt)
+
+	// RAR 5 CMT service block
+	arc50 := []byte(sig50)
+	arc50 = append(arc50, rar5Block(block5Arc, 0, []byte{0}, nil, nil)...)
+	arc50 = append(arc50, rar5Block(block5Service, 0, rar5FileData(serviceComment, cmt), nil, cmt)...)
+	arc50 = append(arc50, rar5Block(block5End, 0, []byte{0}, nil, nil)...)
+
+	// RAR 3.x CMT service block
+	svc := binary.LittleEndian.AppendUint32(nil, uint32(len(cmt))) // packed size
+	svc = binary.LittleEndian.AppendUint32(svc, uint32(len(cmt)))  // unpacked size
+	svc = append(svc, 2)                                           // host os
+	svc = binary.LittleEndian.AppendUint32(svc, crc)
+	svc = binary.LittleEndian.AppendUint32(svc, 0) // file time
+	svc = append(svc, 29, 0x30)                    // unpack version, method
+	svc = binary.LittleEndian.AppendUint16(svc, uint16(len(serviceComment)))
+	svc = binary.LittleEndian.AppendUint32(svc, 0) // attributes
+	svc = append(svc, serviceComment...)
+	arc30 := []byte(sig15)
+	arc30 = append(arc30, rar15Block(blockArc, 0, make([]byte, 6), 0)...)
+	arc30 = append(arc30, rar15Block(blockService, blockHasData, svc, 0)...)
+	arc30 = append(arc30, cmt...)
+	arc30 = append(arc30, rar15Block(blockEnd, 0, nil, 0)...)
+
+	// RAR 2.x comment embedded in the archive block
+	old := binary.LittleEndian.AppendUint16(nil, uint16(len(cmt)))
+	old = append(old, 20, 0x30) // unpack version, method
+	old = binary.LittleEndian.AppendUint16(old, uint16(crc))
+	old = append(old, cmt...)
+	arc20 := []byte(sig15)
+	arc20 = append(arc20, rar15Block(blockArc, arcComment, make([]byte, 6), 0)...)
+	arc20 = append(arc20, rar15Block(blockComment, 0, old, 13)...)
+	arc20 = append(arc20, rar15Block(blockEnd, 0, nil, 0)...)
+
+	// RAR 2.x comment with a bad checksum
+	bad := append([]byte(nil), arc20...)
+	bad[len(bad)-8] ^= 0xff
+
+	// archive without a comment
+	none := []byte(sig50)
+	none = append(none, rar5Block(block5Arc, 0, []byte{0}, nil, nil)...)
+	none = append(none, rar5Block(block5File, 0, rar5FileData("file.txt", cmt), nil, cmt)...)
+	none = append(none, rar5Block(block5End, 0, []byte{0}, nil, nil)...)
+
+	fsys := fstest.MapFS{
+		"arc50.rar": {Data: arc50},
+		"arc30.rar": {Data: arc30},
+		"arc20.rar": {Data: arc20},
+		"bad.rar":   {Data: bad},
+		"none.rar":  {Data: none},
+	}
+	tests := []struct {
+		name    string
+		comment string
+		err     error
+	}{
+		{"arc50.rar", comment, nil},
+		{"arc30.rar", comment, nil},
+		{"arc20.rar", comment, nil},
+		{"bad.rar", "", ErrBadFileChecksum},
+		{"none.rar", "", nil},
+	}
+	for _, tt := range tests {
+		rc, err := OpenReader(tt.name, FileSystem(fsys))
+		if err != nil {
+			t.Fatalf("OpenReader(%s) error = %v", tt.name, err)
+		}
+		got, err := rc.Comment()
+		rc.Close()
+		if got != tt.comment || err != tt.err {
+			t.Errorf("Comment(%s) = %q, %v, want %q, %v", tt.name, got, err, tt.comment, tt.err)
+		}
+	}
+
+	// service blocks must not be returned as files
+	rc, err := OpenReader("arc50.rar", FileSystem(fsys))
+	if err != nil {
+		t.Fatalf("OpenReader() error = %v", err)
+	}
+	defer rc.Close()
+	if h, err := rc.Next(); err == nil {
+		t.Errorf("Next() = %q, want no files", h.Name)
+	}
+}
+
+func TestSkipUnknownService(t *testing.T) {
+	data := []byte("service data")
+	svc := binary.AppendUvarint(nil, 0) // file flags
+	svc = binary.AppendUvarint(svc, uint64(len(data)))
+	svc = binary.AppendUvarint(svc, 0)      // attributes
+	svc = binary.AppendUvarint(svc, 3|1<<7) // unknown algorithm version
+	svc = binary.AppendUvarint(svc, 1)      // host os
+	svc = binary.AppendUvarint(svc, 3)
+	svc = append(svc, "XYZ"...)
+
+	arc50 := []byte(sig50)
+	arc50 = append(arc50, rar5Block(block5Arc, 0, []byte{0}, nil, nil)...)
+	arc50 = append(arc50, rar5Block(block5Service, 0, svc, nil, data)...)
+	arc50 = append(arc50, rar5Block(block5File, 0, rar5FileData("file.txt", data), nil, data)...)
+	arc50 = append(arc50, rar5Block(block5End, 0, []byte{0}, nil, nil)...)
+
+	svc = binary.LittleEndian.AppendUint32(nil, uint32(len(data))) // packed size
+	svc = binary.LittleEndian.AppendUint32(svc, uint32(len(data))) // unpacked size
+	svc = append(svc, 2)                                           // host os
+	svc = binary.LittleEndian.AppendUint32(svc, crc32.ChecksumIEEE(data))
+	svc = binary.LittleEndian.AppendUint32(svc, 0) // file time
+	svc = append(svc, 99, 0x33)                    // unknown unpack version, method
+	svc = binary.LittleEndian.AppendUint16(svc, 3)
+	svc = binary.LittleEndian.AppendUint32(svc, 0) // attributes
+	svc = append(svc, "XYZ"...)
+	arc30 := []byte(sig15)
+	arc30 = append(arc30, rar15Block(blockArc, 0, make([]byte, 6), 0)...)
+	arc30 = append(arc30, rar15Block(blockService, blockHasData, svc, 0)...)
+	arc30 = append(arc30, data...)
+	arc30 = append(arc30, rar15Block(blockFile, blockHasData, rar15File("file.txt", data), 0)...)
+	arc30 = append(arc30, data...)
+	arc30 = append(arc30, rar15Block(blockEnd, 0, nil, 0)...)
+
+	fsys := fstest.MapFS{
+		"arc50.rar": {Data: arc50},
+		"arc30.rar": {Data: arc30},
+	}
+	for name := range fsys {
+		files, err := List(name, FileSystem(fsys))
+		if err != nil || len(files) != 1 || files[0].Name != "file.txt" {
+			t.Errorf("List(%s) = %d files, %v, want file.txt", name, len(files), err)
+		}
+	}
+}
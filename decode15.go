@@ -0,0 +1,485 @@
+package rardecode
+
+import "io"
+
+// Decoding tables used by decodeNum. Each dec table holds the upper bounds of the
+// 16 bit codes for each code length, starting at the given start length. The pos
+// tables hold the first symbol for each code length.
+var (
+	decL1  = []uint{0x8000, 0xa000, 0xc000, 0xd000, 0xe000, 0xea00, 0xee00, 0xf000, 0xf200, 0xf200, 0xffff}
+	posL1  = []uint{0, 0, 0, 2, 3, 5, 7, 11, 16, 20, 24, 32, 32}
+	decL2  = []uint{0xa000, 0xc000, 0xd000, 0xe000, 0xea00, 0xee00, 0xf000, 0xf200, 0xf240, 0xffff}
+	posL2  = []uint{0, 0, 0, 0, 5, 7, 9, 13, 18, 22, 26, 34, 36}
+	decHf0 = []uint{0x8000, 0xc000, 0xe000, 0xf200, 0xf200, 0xf200, 0xf200, 0xf200, 0xffff}
+	posHf0 = []uint{0, 0, 0, 0, 0, 8, 16, 24, 33, 33, 33, 33, 33}
+	decHf1 = []uint{0x2000, 0xc000, 0xe000, 0xf000, 0xf200, 0xf200, 0xf7e0, 0xffff}
+	posHf1 = []uint{0, 0, 0, 0, 0, 0, 4, 44, 60, 76, 80, 80, 127}
+	decHf2 = []uint{0x1000, 0x2400, 0x8000, 0xc000, 0xfa00, 0xffff, 0xffff, 0xffff}
+	posHf2 = []uint{0, 0, 0, 0, 0, 0, 2, 7, 53, 117, 233, 0, 0}
+	decHf3 = []uint{0x800, 0x2400, 0xee00, 0xfe80, 0xffff, 0xffff, 0xffff}
+	posHf3 = []uint{0, 0, 0, 0, 0, 0, 0, 2, 16, 218, 251, 0, 0}
+	decHf4 = []uint{0xff00, 0xffff, 0xffff, 0xffff, 0xffff, 0xffff}
+	posHf4 = []uint{0, 0, 0, 0, 0, 0, 0, 0, 0, 255, 0, 0, 0}
+
+	shortLen1 = [15]uint{1, 3, 4, 4, 5, 6, 7, 8, 8, 4, 4, 5, 6, 6, 4}
+	shortXor1 = [15]uint{0, 0xa0, 0xd0, 0xe0, 0xf0, 0xf8, 0xfc, 0xfe, 0xff, 0xc0, 0x80, 0x90, 0x98, 0x9c, 0xb0}
+	shortLen2 = [15]uint{2, 3, 3, 3, 4, 4, 5, 6, 6, 4, 4, 5, 6, 6, 4}
+	shortXor2 = [15]uint{0, 0x40, 0x60, 0xa0, 0xd0, 0xe0, 0xf0, 0xf8, 0xfc, 0xc0, 0x80, 0x90, 0x98, 0x9c, 0xb0}
+)
+
+const (
+	startL1  = 2
+	startL2  = 3
+	startHf0 = 4
+	startHf1 = 5
+	startHf2 = 5
+	startHf3 = 6
+	startHf4 = 8
+)
+
+// decoder15 implements the decoder interface for RAR 1.5 compression (unpack version 15).
+// It uses adaptive tables for literals, lengths and distances that are reordered as
+// symbols are decoded, and a flags byte that selects the coding of the following symbols.
+type decoder15 struct {
+	r    byteReader
+	size int64  // unpacked bytes left to be decompressed
+	v    uint32 // bit buffer, unread bits are stored from the most significant bit
+	n    int    // number of valid bits in v, negative if input was overrun
+	err  error  // error reading input
+
+	chSet, chSetA, chSetB, chSetC [256]uint16
+	nToPl, nToPlB, nToPlC         [256]byte
+
+	avrPlc, avrPlcB        uint
+	avrLn1, avrLn2, avrLn3 uint
+	numHuf, buf60          uint
+	nhfb, nlzb             uint
+	maxDist3               uint
+	flagBuf                uint
+	flagsCnt               int
+	stMode                 bool
+	lCount                 int
+
+	oldDist    [4]uint
+	oldDistPtr uint
+	lastDist   uint
+	lastLength uint
+}
+
+func (d *decoder15) version() int { return decode15Ver }
+
+// init intializes the decoder for decoding a new file.
+func (d *decoder15) init(r byteReader, reset bool, size int64, ver int) {
+	d.r = r
+	d.v = 0
+	d.n = 0
+	d.err = nil
+	d.size = size
+	if reset {
+		d.oldDist = [4]uint{}
+		d.oldDistPtr = 0
+		d.lastDist = 0
+		d.lastLength = 0
+		d.avrPlcB, d.avrLn1, d.avrLn2, d.avrLn3 = 0, 0, 0, 0
+		d.numHuf, d.buf60 = 0, 0
+		d.avrPlc = 0x3500
+		d.maxDist3 = 0x2001
+		d.nhfb, d.nlzb = 0x80, 0x80
+		d.initHuff()
+	}
+	d.flagsCnt = 0
+	d.flagBuf = 0
+	d.stMode = false
+	d.lCount = 0
+}
+
+func (d *decoder15) initHuff() {
+	for i := range d.chSet {
+		d.chSet[i] = uint16(i << 8)
+		d.chSetB[i] = uint16(i << 8)
+		d.chSetA[i] = uint16(i)
+		d.chSetC[i] = uint16((-i & 0xff) << 8)
+	}
+	clear(d.nToPl[:])
+	clear(d.nToPlB[:])
+	clear(d.nToPlC[:])
+	corrHuff(&d.chSetB, &d.nToPlB)
+}
+
+// corrHuff resets the frequency counts stored in the low byte of each entry in charSet.
+func corrHuff(charSet *[256]uint16, numToPlace *[256]byte) {
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 32; j++ {
+			c := &charSet[i*32+j]
+			*c = *c&^0xff | uint16(7-i)
+		}
+	}
+	clear(numToPlace[:])
+	for i := 6; i >= 0; i-- {
+		numToPlace[i] = byte((7 - i) * 32)
+	}
+}
+
+// getBits returns the next 16 bits of input without consuming them.
+// Zero bits are returned once the end of the input has been reached.
+func (d *decoder15) getBits() uint {
+	for d.n >= 0 && d.n <= 24 && d.err == nil {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			d.err = err
+			break
+		}
+		d.v |= uint32(c) << (24 - d.n)
+		d.n += 8
+	}
+	return uint(d.v >> 16)
+}
+
+// addBits consumes n bits of input.
+func (d *decoder15) addBits(n uint) {
+	d.v <<= n
+	d.n -= int(n)
+}
+
+// readErr returns any error from reading more bits than were available.
+func (d *decoder15) readErr() error {
+	if d.n >= 0 {
+		return nil
+	}
+	if d.err == io.EOF {
+		return ErrDecoderOutOfData
+	}
+	return d.err
+}
+
+// decodeNum decodes a symbol from num using the given decoding tables.
+func (d *decoder15) decodeNum(num, start uint, dec, pos []uint) uint {
+	num &= 0xfff0
+	i := 0
+	for dec[i] <= num {
+		i++
+		start++
+	}
+	d.addBits(start)
+	var base uint
+	if i > 0 {
+		base = dec[i-1]
+	}
+	return (num-base)>>(16-start) + pos[start]
+}
+
+func (d *decoder15) copyString(dr *decodeReader, distance, length uint) {
+	d.size -= int64(length)
+	dr.copyBytes(int(length), int(distance))
+}
+
+func (d *decoder15) getFlagsBuf() {
+	place := d.decodeNum(d.getBits(), startHf2, decHf2, posHf2)
+	if place >= uint(len(d.chSetC)) {
+		return // only possible with corrupt data
+	}
+	var flags, newPlace uint
+	for {
+		flags = uint(d.chSetC[place])
+		d.flagBuf = flags >> 8
+		newPlace = uint(d.nToPlC[flags&0xff])
+		d.nToPlC[flags&0xff]++
+		flags++
+		if flags&0xff != 0 {
+			break
+		}
+		corrHuff(&d.chSetC, &d.nToPlC)
+	}
+	d.chSetC[place] = d.chSetC[newPlace]
+	d.chSetC[newPlace] = uint16(flags)
+}
+
+// nextFlag returns the next bit from the flags byte, reading a new one when required.
+func (d *decoder15) nextFlag() bool {
+	d.flagsCnt--
+	if d.flagsCnt < 0 {
+		d.getFlagsBuf()
+		d.flagsCnt = 7
+	}
+	f := d.flagBuf&0x80 != 0
+	d.flagBuf = (d.flagBuf << 1) & 0xff
+	return f
+}
+
+func (d *decoder15) shortLZ(dr *decodeReader) {
+	d.numHuf = 0
+	bits := d.getBits()
+	if d.lCount == 2 {
+		d.addBits(1)
+		if bits >= 0x8000 {
+			d.copyString(dr, d.lastDist, d.lastLength)
+			return
+		}
+		bits <<= 1
+		d.lCount = 0
+	}
+	bits = (bits >> 8) & 0xff
+
+	lens, xors := &shortLen1, &shortXor1
+	buf60Pos := 1
+	if d.avrLn1 >= 37 {
+		lens, xors = &shortLen2, &shortXor2
+		buf60Pos = 3
+	}
+	var length, l uint
+	for ; length < uint(len(lens)); length++ {
+		l = lens[length]
+		if int(length) == buf60Pos {
+			l = d.buf60 + 3
+		}
+		if (bits^xors[length])&^(0xff>>l)&0xff == 0 {
+			break
+		}
+	}
+	d.addBits(l)
+
+	if length >= 9 {
+		if length == 9 {
+			d.lCount++
+			d.copyString(dr, d.lastDist, d.lastLength)
+			return
+		}
+		if length == 14 {
+			d.lCount = 0
+			length = d.decodeNum(d.getBits(), startL2, decL2, posL2) + 5
+			distance := d.getBits()>>1 | 0x8000
+			d.addBits(15)
+			d.lastLength = length
+			d.lastDist = distance
+			d.copyString(dr, distance, length)
+			return
+		}
+
+		d.lCount = 0
+		saveLength := length
+		distance := d.oldDist[(d.oldDistPtr-(length-9))&3]
+		length = d.decodeNum(d.getBits(), startL1, decL1, posL1) + 2
+		if length == 0x101 && saveLength == 10 {
+			d.buf60 ^= 1
+			return
+		}
+		if distance > 256 {
+			length++
+		}
+		if distance >= d.maxDist3 {
+			length++
+		}
+		d.oldDist[d.oldDistPtr] = distance
+		d.oldDistPtr = (d.oldDistPtr + 1) & 3
+		d.lastLength = length
+		d.lastDist = distance
+		d.copyString(dr, distance, length)
+		return
+	}
+
+	d.lCount = 0
+	d.avrLn1 += length
+	d.avrLn1 -= d.avrLn1 >> 4
+
+	place := int(d.decodeNum(d.getBits(), startHf2, decHf2, posHf2) & 0xff)
+	distance := uint(d.chSetA[place])
+	if place--; place != -1 {
+		d.chSetA[place+1] = d.chSetA[place]
+		d.chSetA[place] = uint16(distance)
+	}
+	length += 2
+	distance++
+	d.oldDist[d.oldDistPtr] = distance
+	d.oldDistPtr = (d.oldDistPtr + 1) & 3
+	d.lastLength = length
+	d.lastDist = distance
+	d.copyString(dr, distance, length)
+}
+
+func (d *decoder15) longLZ(dr *decodeReader) {
+	d.numHuf = 0
+	d.nlzb += 16
+	if d.nlzb > 0xff {
+		d.nlzb = 0x90
+		d.nhfb >>= 1
+	}
+	oldAvr2 := d.avrLn2
+
+	var length uint
+	bits := d.getBits()
+	switch {
+	case d.avrLn2 >= 122:
+		length = d.decodeNum(bits, startL2, decL2, posL2)
+	case d.avrLn2 >= 64:
+		length = d.decodeNum(bits, startL1, decL1, posL1)
+	case bits < 0x100:
+		length = bits
+		d.addBits(16)
+	default:
+		for bits<<length&0x8000 == 0 {
+			length++
+		}
+		d.addBits(length + 1)
+	}
+	d.avrLn2 += length
+	d.avrLn2 -= d.avrLn2 >> 5
+
+	var place uint
+	bits = d.getBits()
+	switch {
+	case d.avrPlcB > 0x28ff:
+		place = d.decodeNum(bits, startHf2, decHf2, posHf2)
+	case d.avrPlcB > 0x6ff:
+		place = d.decodeNum(bits, startHf1, decHf1, posHf1)
+	default:
+		place = d.decodeNum(bits, startHf0, decHf0, posHf0)
+	}
+	d.avrPlcB += place
+	d.avrPlcB -= d.avrPlcB >> 8
+
+	var distance, newPlace uint
+	for {
+		distance = uint(d.chSetB[place&0xff])
+		newPlace = uint(d.nToPlB[distance&0xff])
+		d.nToPlB[distance&0xff]++
+		distance++
+		if distance&0xff != 0 {
+			break
+		}
+		corrHuff(&d.chSetB, &d.nToPlB)
+	}
+	d.chSetB[place&0xff] = d.chSetB[newPlace]
+	d.chSetB[newPlace] = uint16(distance)
+
+	distance = (distance&0xff00 | d.getBits()>>8) >> 1
+	d.addBits(7)
+
+	oldAvr3 := d.avrLn3
+	if length != 1 && length != 4 {
+		if length == 0 && distance <= d.maxDist3 {
+			d.avrLn3++
+			d.avrLn3 -= d.avrLn3 >> 8
+		} else if d.avrLn3 > 0 {
+			d.avrLn3--
+		}
+	}
+	length += 3
+	if distance >= d.maxDist3 {
+		length++
+	}
+	if distance <= 256 {
+		length += 8
+	}
+	if oldAvr3 > 0xb0 || d.avrPlc >= 0x2a00 && oldAvr2 < 0x40 {
+		d.maxDist3 = 0x7f00
+	} else {
+		d.maxDist3 = 0x2001
+	}
+	d.oldDist[d.oldDistPtr] = distance
+	d.oldDistPtr = (d.oldDistPtr + 1) & 3
+	d.lastLength = length
+	d.lastDist = distance
+	d.copyString(dr, distance, length)
+}
+
+func (d *decoder15) huffDecode(dr *decodeReader) {
+	var place uint
+	bits := d.getBits()
+	switch {
+	case d.avrPlc > 0x75ff:
+		place = d.decodeNum(bits, startHf4, decHf4, posHf4)
+	case d.avrPlc > 0x5dff:
+		place = d.decodeNum(bits, startHf3, decHf3, posHf3)
+	case d.avrPlc > 0x35ff:
+		place = d.decodeNum(bits, startHf2, decHf2, posHf2)
+	case d.avrPlc > 0x0dff:
+		place = d.decodeNum(bits, startHf1, decHf1, posHf1)
+	default:
+		place = d.decodeNum(bits, startHf0, decHf0, posHf0)
+	}
+	place &= 0xff
+	if d.stMode {
+		if place == 0 && bits > 0xfff {
+			place = 0x100
+		}
+		if place == 0 {
+			bits = d.getBits()
+			d.addBits(1)
+			if bits&0x8000 != 0 {
+				d.numHuf = 0
+				d.stMode = false
+				return
+			}
+			length := uint(3)
+			if bits&0x4000 != 0 {
+				length = 4
+			}
+			d.addBits(1)
+			distance := d.decodeNum(d.getBits(), startHf2, decHf2, posHf2)
+			distance = distance<<5 | d.getBits()>>11
+			d.addBits(5)
+			d.copyString(dr, distance, length)
+			return
+		}
+		place--
+	} else {
+		if d.numHuf >= 16 && d.flagsCnt == 0 {
+			d.stMode = true
+		}
+		d.numHuf++
+	}
+	d.avrPlc += place
+	d.avrPlc -= d.avrPlc >> 8
+	d.nhfb += 16
+	if d.nhfb > 0xff {
+		d.nhfb = 0x90
+		d.nlzb >>= 1
+	}
+
+	dr.writeByte(byte(d.chSet[place] >> 8))
+	d.size--
+
+	var cur, newPlace uint
+	for {
+		cur = uint(d.chSet[place])
+		newPlace = uint(d.nToPl[cur&0xff])
+		d.nToPl[cur&0xff]++
+		cur++
+		if cur&0xff <= 0xa1 {
+			break
+		}
+		corrHuff(&d.chSet, &d.nToPl)
+	}
+	d.chSet[place] = d.chSet[newPlace]
+	d.chSet[newPlace] = uint16(cur)
+}
+
+func (d *decoder15) fill(dr *decodeReader) error {
+	for d.size > 0 && dr.notFull() {
+		switch {
+		case d.stMode:
+			d.huffDecode(dr)
+		case d.nextFlag():
+			if d.nlzb > d.nhfb {
+				d.longLZ(dr)
+			} else {
+				d.huffDecode(dr)
+			}
+		case d.nextFlag():
+			if d.nlzb > d.nhfb {
+				d.huffDecode(dr)
+			} else {
+				d.longLZ(dr)
+			}
+		default:
+			d.shortLZ(dr)
+		}
+		if err := d.readErr(); err != nil {
+			return err
+		}
+	}
+	if d.size <= 0 {
+		return io.EOF
+	}
+	return nil
+}
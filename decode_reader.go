@@ -13,7 +13,7 @@ const (
 var (
 	ErrTooManyFilters   = errors.New("rardecode: too many filters")
 	ErrInvalidFilter    = errors.New("rardecode: invalid filter")
-	ErrMultipleDecoders = errors.New("rardecode: multiple decoders in a single archive not supported")
+	ErrMultipleDecoders = errors.New("rardecode: solid file uses a different decoder to the previous file")
 )
 
 // filter functions take a byte slice, the current output offset and
@@ -81,24 +81,45 @@ func (d *decodeReader) init(f archiveFile, ver int, size int, reset, arcSolid bo
 	d.r = d.w
 
 	// initialize decoder
-	if d.dec == nil {
-		switch ver {
-		case decode29Ver:
-			d.dec = new(decoder29)
-		case decode50Ver, decode70Ver:
-			d.dec = new(decoder50)
-		case decode20Ver:
-			d.dec = new(decoder20)
-		default:
-			return ErrUnknownDecoder
+	if d.dec == nil || d.dec.version() != decoderVersion(ver) {
+		// a solid file must be decoded by the decoder used for the previous file
+		if d.dec != nil && !reset {
+			return ErrMultipleDecoders
 		}
-	} else if d.dec.version() != ver {
-		return ErrMultipleDecoders
+		dec, err := newDecoder(ver)
+		if err != nil {
+			return err
+		}
+		d.dec = dec
 	}
 	d.dec.init(f, reset, unPackedSize, ver)
 	return nil
 }
 
+// decoderVersion returns the version of the decoder used for files packed with
+// decoder version ver. RAR 7 files use the RAR 5 decoder with a larger window.
+func decoderVersion(ver int) int {
+	if ver == decode70Ver {
+		return decode50Ver
+	}
+	return ver
+}
+
+// newDecoder returns a new decoder for files packed with decoder version ver.
+func newDecoder(ver int) (decoder, error) {
+	switch ver {
+	case decode29Ver:
+		return new(decoder29), nil
+	case decode50Ver, decode70Ver:
+		return new(decoder50), nil
+	case decode20Ver:
+		return new(decoder20), nil
+	case decode15Ver:
+		return new(decoder15), nil
+	}
+	return nil, ErrUnknownDecoder
+}
+
 // notFull returns if the window is not full
 func (d *decodeReader) notFull() bool { return d.w < d.size }
 
@@ -0,0 +1,35 @@
+package rardecode
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeReaderInitVersions(t *testing.T) {
+	tests := []struct {
+		ver   int
+		reset bool
+		dec   decoder
+		err   error
+	}{
+		{decode50Ver, true, &decoder50{}, nil},
+		{decode70Ver, false, &decoder50{}, nil},
+		{decode50Ver, false, &decoder50{}, nil},
+		{decode29Ver, false, nil, ErrMultipleDecoders},
+		{decode29Ver, true, &decoder29{}, nil},
+		{decode20Ver, true, &decoder20{}, nil},
+		{decode20Ver, false, &decoder20{}, nil},
+		{decode15Ver, true, &decoder15{}, nil},
+	}
+	d := new(decodeReader)
+	for i, tt := range tests {
+		err := d.init(nil, tt.ver, minWindowSize, tt.reset, false, 0)
+		if err != tt.err {
+			t.Errorf("file %d: init(%d, reset %v) error = %v, want %v", i, tt.ver, tt.reset, err, tt.err)
+			continue
+		}
+		if tt.dec != nil && reflect.TypeOf(d.dec) != reflect.TypeOf(tt.dec) {
+			t.Errorf("file %d: decoder = %T, want %T", i, d.dec, tt.dec)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"io"
+	"io/fs"
 )
 
 // cipherBlockReader implements Block Mode decryption of an io.Reader object.
@@ -156,10 +157,82 @@ func (cr *cipherBlockFileReader) writeToN(w io.Writer, n int64) (int64, error) {
 	return cr.cbr.writeToN(w, n)
 }
 
-func newAesDecryptFileReader(r archiveFile, key, iv []byte) (*cipherBlockFileReader, error) {
-	cbr, err := newAesDecryptReader(r, key, iv)
+// cipherBlockFileReadSeeker decrypts an AES encrypted file that can be seeked.
+// CBC mode allows decryption to start at any block, using the previous encrypted
+// block as the IV.
+type cipherBlockFileReadSeeker struct {
+	cipherBlockFileReader
+	sr    io.Seeker
+	block cipher.Block
+	iv    []byte // IV of the first block
+}
+
+func (cr *cipherBlockFileReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	cbr := cr.cbr
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		cur, err := cr.sr.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, err
+		}
+		offset += cur - int64(len(cbr.inbuf)+len(cbr.outbuf))
+	case io.SeekEnd:
+		end, err := cr.sr.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, err
+		}
+		offset += end
+	default:
+		return 0, fs.ErrInvalid
+	}
+	if offset < 0 {
+		return 0, fs.ErrInvalid
+	}
+	// start decrypting at the block containing offset
+	bs := int64(cbr.mode.BlockSize())
+	start := offset - offset%bs
+	iv := cr.iv
+	if start > 0 {
+		_, err := cr.sr.Seek(start-bs, io.SeekStart)
+		if err != nil {
+			return 0, err
+		}
+		iv = make([]byte, bs)
+		_, err = io.ReadFull(cr.archiveFile, iv)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		_, err := cr.sr.Seek(0, io.SeekStart)
+		if err != nil {
+			return 0, err
+		}
+	}
+	cbr.mode = cipher.NewCBCDecrypter(cr.block, iv)
+	cbr.inbuf = nil
+	cbr.outbuf = nil
+	if skip := offset - start; skip > 0 {
+		err := cbr.fillOutbuf()
+		if err != nil {
+			return 0, err
+		}
+		cbr.outbuf = cbr.outbuf[skip:]
+	}
+	return offset, nil
+}
+
+// newAesDecryptFileReader returns an archiveFile that decrypts r using AES.
+// The returned archiveFile can be seeked if r can be seeked.
+func newAesDecryptFileReader(r archiveFile, key, iv []byte) (archiveFile, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-	return &cipherBlockFileReader{archiveFile: r, cbr: cbr}, nil
+	cbr := newCipherBlockReader(r, cipher.NewCBCDecrypter(block, iv))
+	cr := cipherBlockFileReader{archiveFile: r, cbr: cbr}
+	if sr, ok := r.(io.Seeker); ok {
+		return &cipherBlockFileReadSeeker{cipherBlockFileReader: cr, sr: sr, block: block, iv: iv}, nil
+	}
+	return &cr, nil
 }
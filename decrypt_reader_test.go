@@ -0,0 +1,95 @@
+package rardecode
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSeekEncryptedFile(t *testing.T) {
+	contents := []byte("encrypted stored file split across volumes")
+	salt := make([]byte, 16)
+	iv := make([]byte, 16)
+	iv[0] = 1
+	key := calcKeys50([]byte("password"), salt, 1)[0]
+	packed := append([]byte{}, contents...)
+	packed = append(packed, make([]byte, -len(packed)&15)...)
+	block, _ := aes.NewCipher(key)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(packed, packed)
+
+	// file encryption record with a KDF count of 2^0
+	rec := binary.AppendUvarint(nil, 1)
+	rec = append(rec, 0, 0, 0)
+	rec = append(append(rec, salt...), iv...)
+	extra := append(binary.AppendUvarint(nil, uint64(len(rec))), rec...)
+	fd := rar5FileData("a.txt", contents)
+
+	fsys := fstest.MapFS{}
+	parts := [][]byte{packed[:32], packed[32:]}
+	for i, part := range parts {
+		var flags uint64
+		arc := []byte{arc5MultiVol}
+		end := byte(endArc5NotLast)
+		if i == 0 {
+			flags = block5DataNotLast
+		} else {
+			flags = block5DataNotFirst
+			arc = []byte{arc5MultiVol | arc5VolNum, byte(i)}
+			end = 0
+		}
+		v := []byte(sig50)
+		v = append(v, rar5Block(block5Arc, 0, arc, nil, nil)...)
+		v = append(v, rar5Block(block5File, flags, fd, extra, part)...)
+		v = append(v, rar5Block(block5End, 0, []byte{end}, nil, nil)...)
+		fsys[fmt.Sprintf("arc.part%d.rar", i+1)] = &fstest.MapFile{Data: v}
+	}
+
+	rfs, err := OpenFS("arc.part1.rar", FileSystem(fsys), Password("password"))
+	if err != nil {
+		t.Fatalf("OpenFS error = %v", err)
+	}
+	f, err := rfs.Open("a.txt")
+	if err != nil {
+		t.Fatalf("Open error = %v", err)
+	}
+	defer f.Close()
+	if _, ok := f.(*fileSeekCloser); !ok {
+		t.Fatalf("encrypted file is a %T, want *fileSeekCloser", f)
+	}
+	sr := f.(io.ReadSeeker)
+	buf := make([]byte, 8)
+	for _, off := range []int64{20, 3, 28, 0, 34} { // 28 reads across the volumes
+		pos, err := sr.Seek(off, io.SeekStart)
+		if err != nil || pos != off {
+			t.Fatalf("Seek(%d) = %d, %v", off, pos, err)
+		}
+		n, err := io.ReadFull(sr, buf)
+		if err != nil || string(buf[:n]) != string(contents[off:off+8]) {
+			t.Errorf("read at %d = %q, %v, want %q", off, buf[:n], err, contents[off:off+8])
+		}
+	}
+	pos, err := sr.Seek(-6, io.SeekEnd)
+	if err != nil || pos != int64(len(contents)-6) {
+		t.Fatalf("Seek(-6, SeekEnd) = %d, %v", pos, err)
+	}
+	b, err := io.ReadAll(sr)
+	if err != nil || string(b) != string(contents[pos:]) {
+		t.Errorf("read at %d = %q, %v, want %q", pos, b, err, contents[pos:])
+	}
+
+	ra := f.(io.ReaderAt)
+	n, err := ra.ReadAt(buf, int64(len(contents)-4))
+	if err != io.EOF || string(buf[:n]) != string(contents[len(contents)-4:]) {
+		t.Errorf("ReadAt(%d) = %q, %v, want %q, EOF", len(contents)-4, buf[:n], err, contents[len(contents)-4:])
+	}
+	// the checksum is checked when reading from the start
+	sr.Seek(0, io.SeekStart)
+	b, err = io.ReadAll(sr)
+	if err != nil || string(b) != string(contents) {
+		t.Errorf("read whole file = %q, %v, want %q", b, err, contents)
+	}
+}
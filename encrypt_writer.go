@@ -0,0 +1,138 @@
+package rardecode
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+const (
+	writeKdfCount = 15 // log2 of the PBKDF2 iteration count used by a Writer
+	saltSize50    = 16 // size of the salt used by a Writer
+)
+
+// writeKeys holds the keys used by a Writer to encrypt file data and block headers.
+type writeKeys struct {
+	salt  []byte
+	keys  [][]byte     // keys returned by calcKeys50
+	block cipher.Block // AES cipher using keys[0]
+}
+
+func newWriteKeys(pass string) (*writeKeys, error) {
+	salt := make([]byte, saltSize50)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+	return newWriteKeysSalt(pass, salt)
+}
+
+// newWriteKeysSalt returns the keys derived from pass and salt.
+func newWriteKeysSalt(pass string, salt []byte) (*writeKeys, error) {
+	keys := calcKeys50([]byte(pass), salt, 1<<writeKdfCount)
+	block, err := aes.NewCipher(keys[0])
+	if err != nil {
+		return nil, err
+	}
+	return &writeKeys{salt: salt, keys: keys, block: block}, nil
+}
+
+// newIV returns a random AES initialization vector.
+func newIV() ([]byte, error) {
+	iv := make([]byte, aes.BlockSize)
+	_, err := rand.Read(iv)
+	return iv, err
+}
+
+// encryptionBlock returns the RAR 5 archive encryption block that precedes the
+// encrypted block headers of a volume.
+func (k *writeKeys) encryptionBlock() []byte {
+	fields := binary.AppendUvarint(nil, 0) // version
+	fields = binary.AppendUvarint(fields, enc5CheckPresent)
+	fields = append(fields, writeKdfCount)
+	fields = append(fields, k.salt...)
+	fields = append(fields, k.keys[2]...)
+	return appendBlock50(nil, block5Encrypt, 0, fields, nil, 0, false)
+}
+
+// fileEncryptionRecord appends the file encryption record for a file encrypted with iv to b.
+// If mac is set, the record flags the file checksum as a MAC.
+func (k *writeKeys) fileEncryptionRecord(b, iv []byte, mac bool) []byte {
+	flags := uint64(file5EncCheckPresent)
+	if mac {
+		flags |= file5EncUseMac
+	}
+	data := binary.AppendUvarint(nil, 0) // version
+	data = binary.AppendUvarint(data, flags)
+	data = append(data, writeKdfCount)
+	data = append(data, k.salt...)
+	data = append(data, iv...)
+	data = append(data, k.keys[2]...)
+	return appendExtra50(b, 1, data)
+}
+
+// macSum converts a file checksum to the MAC stored for encrypted files using
+// key, so the checksum can't be used to verify guesses of the file contents.
+func macSum(key, sum []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(sum)
+	m := mac.Sum(nil)
+	if len(sum) == 4 {
+		// CRC32
+		for i, v := range m[4:] {
+			m[i&3] ^= v
+		}
+		m = m[:4]
+	}
+	return m
+}
+
+// sealHeader returns the block header hdr encrypted with iv, preceded by iv.
+func (k *writeKeys) sealHeader(hdr, iv []byte) []byte {
+	n := (len(hdr) + aes.BlockSize - 1) / aes.BlockSize * aes.BlockSize
+	b := make([]byte, len(iv)+n)
+	copy(b, iv)
+	copy(b[len(iv):], hdr)
+	cipher.NewCBCEncrypter(k.block, iv).CryptBlocks(b[len(iv):], b[len(iv):])
+	return b
+}
+
+// sealedHeaderSize returns the size of an encrypted block header of n bytes.
+func sealedHeaderSize(n int) int {
+	return aes.BlockSize + (n+aes.BlockSize-1)/aes.BlockSize*aes.BlockSize
+}
+
+// cipherBlockWriter encrypts the contents of a file, buffering any trailing
+// partial block until more data is written or the file ends.
+type cipherBlockWriter struct {
+	mode cipher.BlockMode
+	buf  []byte // plain text not yet encrypted
+}
+
+// encrypt returns the whole cipher blocks of the data written so far including p.
+// If final is set, the last partial block is padded with zeros and included.
+func (cw *cipherBlockWriter) encrypt(p []byte, final bool) []byte {
+	b := make([]byte, 0, len(cw.buf)+len(p)+aes.BlockSize)
+	b = append(append(b, cw.buf...), p...)
+	n := len(b) - len(b)%aes.BlockSize
+	if final && n < len(b) {
+		n += aes.BlockSize
+		b = b[:n]
+	}
+	cw.buf = append(cw.buf[:0], b[n:]...)
+	b = b[:n]
+	cw.mode.CryptBlocks(b, b)
+	return b
+}
+
+func newCipherBlockWriter(block cipher.Block, iv []byte) *cipherBlockWriter {
+	return &cipherBlockWriter{mode: cipher.NewCBCEncrypter(block, iv)}
+}
+
+// encryptedSize returns the size of size bytes of data once encrypted.
+func encryptedSize(size int64) int64 {
+	return (size + aes.BlockSize - 1) / aes.BlockSize * aes.BlockSize
+}
@@ -0,0 +1,182 @@
+package rardecode
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+)
+
+var errExtractStopped = errors.New("rardecode: extract stopped")
+
+// ExtractFunc is called by an Extractor with the header and contents of each
+// file in the archive. The contents are only valid until ExtractFunc returns.
+// Any contents not read by ExtractFunc are decoded and discarded, so that the
+// files that follow in the same solid group can be decoded.
+type ExtractFunc func(h *FileHeader, r io.Reader) error
+
+// Extractor decodes the files in a RAR archive concurrently.
+//
+// Files that don't depend on any other file, such as the files in a non-solid
+// archive, can be decoded independently. A solid archive is split into groups
+// of files where each group starts with a file that resets the dictionary, by
+// not having its Solid flag set. The files in a group are decoded in archive
+// order, while different groups are decoded concurrently.
+type Extractor struct {
+	vm     *volumeManager
+	files  []*fileBlockList
+	groups [][]*fileBlockList // files that must be decoded in order by a single decoder
+}
+
+// Files returns the headers of the files in the archive in archive order.
+func (e *Extractor) Files() []*FileHeader {
+	list := make([]*FileHeader, len(e.files))
+	for i, blocks := range e.files {
+		list[i] = &blocks.firstBlock().FileHeader
+	}
+	return list
+}
+
+// Volumes returns the volume filenames of the archive that have been opened so far.
+func (e *Extractor) Volumes() []string {
+	return e.vm.Files()
+}
+
+// stopReader reads from r until stop returns true, after which Read returns
+// errExtractStopped, so a large file isn't decoded to the end once another
+// group has failed.
+type stopReader struct {
+	r    io.Reader
+	stop func() bool
+}
+
+func (sr stopReader) Read(p []byte) (int, error) {
+	if sr.stop() {
+		return 0, errExtractStopped
+	}
+	return sr.r.Read(p)
+}
+
+// extractGroup decodes the files in group in order using a single decoder,
+// calling fn for each file. stop is checked before each file is decoded and
+// while it is read.
+func (e *Extractor) extractGroup(group []*fileBlockList, fn ExtractFunc, stop func() bool) error {
+	var dr *decodeReader
+	for _, blocks := range group {
+		if stop() {
+			return nil
+		}
+		h := blocks.firstBlock()
+		v, err := e.vm.openBlockOffset(h, 0)
+		if err != nil {
+			return err
+		}
+		pr := &packedFileReader{v: v, opt: e.vm.opt, dr: dr}
+		f, err := pr.newArchiveFile(blocks)
+		if err == nil {
+			r := stopReader{r: f, stop: stop}
+			err = fn(&h.FileHeader, r)
+			if err == nil {
+				// the rest of the file is needed by the following solid files
+				_, err = io.Copy(io.Discard, r)
+			}
+		}
+		v.Close()
+		if errors.Is(err, errExtractStopped) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		dr = pr.dr
+	}
+	return nil
+}
+
+// Extract decodes every file in the archive, calling fn with each file's header
+// and contents. Up to workers groups of files are decoded concurrently, so fn
+// may be called from multiple goroutines at once. If workers is less than 1,
+// the number of CPUs is used. Extract stops at the first error returned by fn
+// or encountered decoding a file, and returns that error.
+func (e *Extractor) Extract(workers int, fn ExtractFunc) error {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	workers = min(workers, len(e.groups))
+
+	var mu sync.Mutex
+	var firstErr error
+	setErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	stop := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+
+	groups := make(chan []*fileBlockList)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range groups {
+				err := e.extractGroup(group, fn, stop)
+				if err != nil {
+					setErr(err)
+				}
+			}
+		}()
+	}
+	for _, group := range e.groups {
+		if stop() {
+			break
+		}
+		groups <- group
+	}
+	close(groups)
+	wg.Wait()
+	return firstErr
+}
+
+// solidGroups splits files into groups that can be decoded independently.
+// A compressed file with the Solid flag set continues the group of the
+// compressed file before it, while files without compressed data don't use
+// the decoder and are placed in their own group.
+func solidGroups(files []*fileBlockList) [][]*fileBlockList {
+	var groups [][]*fileBlockList
+	last := -1 // index of the group of the last compressed file
+	for _, blocks := range files {
+		h := blocks.firstBlock()
+		if h.decVer == 0 || h.isSymlink() || h.isFileRef() {
+			groups = append(groups, []*fileBlockList{blocks})
+			continue
+		}
+		if h.Solid && last >= 0 {
+			groups[last] = append(groups[last], blocks)
+			continue
+		}
+		groups = append(groups, []*fileBlockList{blocks})
+		last = len(groups) - 1
+	}
+	return groups
+}
+
+// OpenExtractor opens the RAR archive specified by name and returns an Extractor
+// for decoding its files concurrently.
+func OpenExtractor(name string, opts ...Option) (*Extractor, error) {
+	vm, files, err := listFileBlocks(name, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Extractor{
+		vm:     vm,
+		files:  files,
+		groups: solidGroups(files),
+	}, nil
+}
@@ -0,0 +1,156 @@
+package rardecode
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSolidGroups(t *testing.T) {
+	file := func(name string, decVer int, solid bool) *fileBlockList {
+		h := &fileBlockHeader{decVer: decVer}
+		h.Name = name
+		h.Solid = solid
+		return newFileBlockList(h)
+	}
+	files := []*fileBlockList{
+		file("a", decode50Ver, false),
+		file("b", decode50Ver, true),
+		file("dir", 0, true),
+		file("c", decode50Ver, true),
+		file("d", decode50Ver, false),
+		file("e", decode50Ver, true),
+		file("f", decode50Ver, false),
+	}
+	want := [][]string{{"a", "b", "c"}, {"dir"}, {"d", "e"}, {"f"}}
+	groups := solidGroups(files)
+	if len(groups) != len(want) {
+		t.Fatalf("solidGroups returned %d groups, want %d", len(groups), len(want))
+	}
+	for i, group := range groups {
+		var names []string
+		for _, blocks := range group {
+			names = append(names, blocks.firstBlock().Name)
+		}
+		if len(names) != len(want[i]) {
+			t.Errorf("group %d = %v, want %v", i, names, want[i])
+			continue
+		}
+		for j := range names {
+			if names[j] != want[i][j] {
+				t.Errorf("group %d = %v, want %v", i, names, want[i])
+				break
+			}
+		}
+	}
+}
+
+func TestExtractor(t *testing.T) {
+	contents := map[string]string{
+		"a.txt": "first file",
+		"b.txt": "second file",
+		"c.txt": "third file",
+	}
+	arc := []byte(sig50)
+	arc = append(arc, rar5Block(block5Arc, 0, []byte{0}, nil, nil)...)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		b := []byte(contents[name])
+		arc = append(arc, rar5Block(block5File, 0, rar5FileData(name, b), nil, b)...)
+	}
+	arc = append(arc, rar5Block(block5End, 0, []byte{0}, nil, nil)...)
+	fsys := fstest.MapFS{"arc.rar": {Data: arc}}
+
+	e, err := OpenExtractor("arc.rar", FileSystem(fsys))
+	if err != nil {
+		t.Fatalf("OpenExtractor error = %v", err)
+	}
+	if n := len(e.Files()); n != len(contents) {
+		t.Fatalf("Files returned %d files, want %d", n, len(contents))
+	}
+	var mu sync.Mutex
+	got := map[string]string{}
+	err = e.Extract(2, func(h *FileHeader, r io.Reader) error {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		got[h.Name] = string(b)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Extract error = %v", err)
+	}
+	for name, want := range contents {
+		if got[name] != want {
+			t.Errorf("extracted %s = %q, want %q", name, got[name], want)
+		}
+	}
+
+	// solid RAR 1.5 group, the second file uses the tables of the first, see TestDecoder15
+	solid := []byte(sig15)
+	solid = append(solid, rar15Block(blockArc, arcSolid, make([]byte, 6), 0)...)
+	solid = append(solid, rar15Packed("s1.txt", "ABBB", []byte{0x8b, 0xe5, 0xe6, 0x00})...)
+	solid = append(solid, rar15PackedFlags("s2.txt", "ABBB", []byte{0x00, 0x02, 0x00}, fileSolid)...)
+	solid = append(solid, rar15Block(blockFile, blockHasData, rar15File("c.txt", []byte("stored")), 0)...)
+	solid = append(solid, "stored"...)
+	solid = append(solid, rar15Block(blockEnd, 0, nil, 0)...)
+	fsys["solid.rar"] = &fstest.MapFile{Data: solid}
+	se, err := OpenExtractor("solid.rar", FileSystem(fsys))
+	if err != nil {
+		t.Fatalf("OpenExtractor error = %v", err)
+	}
+	if len(se.groups) != 2 || len(se.groups[0]) != 2 {
+		t.Fatalf("solid archive split into %d groups, want a group of 2 files and a stored file", len(se.groups))
+	}
+	clear(got)
+	err = se.Extract(2, func(h *FileHeader, r io.Reader) error {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		got[h.Name] = string(b)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Extract solid error = %v", err)
+	}
+	for name, want := range map[string]string{"s1.txt": "ABBB", "s2.txt": "ABBB", "c.txt": "stored"} {
+		if got[name] != want {
+			t.Errorf("extracted %s = %q, want %q", name, got[name], want)
+		}
+	}
+
+	// a group stops decoding while a file is read once another group has failed
+	var stopped atomic.Bool
+	var calls int
+	err = se.extractGroup(se.groups[0], func(h *FileHeader, r io.Reader) error {
+		calls++
+		b := make([]byte, 1)
+		if _, err := r.Read(b); err != nil {
+			return err
+		}
+		stopped.Store(true)
+		if _, err := io.ReadAll(r); err != errExtractStopped {
+			t.Errorf("read after stop error = %v, want %v", err, errExtractStopped)
+		}
+		return nil
+	}, stopped.Load)
+	if err != nil || calls != 1 {
+		t.Errorf("stopped extractGroup = %v after %d files, want nil after 1", err, calls)
+	}
+
+	errSink := errors.New("sink error")
+	err = e.Extract(1, func(h *FileHeader, r io.Reader) error {
+		return errSink
+	})
+	if err != errSink {
+		t.Errorf("Extract error = %v, want %v", err, errSink)
+	}
+}
@@ -1,6 +1,7 @@
 package rardecode
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -10,6 +11,12 @@ import (
 	"time"
 )
 
+const maxSymlinks = 40 // maximum number of symbolic links followed when resolving a name
+
+var (
+	ErrTooManyLinks = errors.New("rardecode: too many levels of symbolic links")
+)
+
 type fileInfo struct {
 	h *fileBlockHeader
 }
@@ -19,7 +26,10 @@ func (f fileInfo) Size() int64        { return f.h.UnPackedSize }
 func (f fileInfo) Mode() fs.FileMode  { return f.h.Mode() }
 func (f fileInfo) ModTime() time.Time { return f.h.ModificationTime }
 func (f fileInfo) IsDir() bool        { return f.h.IsDir }
-func (f fileInfo) Sys() any           { return nil }
+
+// Sys returns a copy of the *FileHeader of the file, which can be changed
+// without affecting the archive.
+func (f fileInfo) Sys() any { return f.h.FileHeader.clone() }
 
 type dirEntry struct {
 	h *fileBlockHeader
@@ -81,15 +91,48 @@ func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
 }
 
 type fsNode struct {
-	name   string
-	blocks *fileBlockList
-	files  []*fsNode
+	name     string
+	blocks   *fileBlockList
+	versions []*fileBlockList // all versions of the file sorted by version number
+	ref      *fsNode          // referenced file for hard links and file copies
+	files    []*fsNode
+}
+
+// addVersion adds a version of the file to the node. The version with the
+// highest version number is used as the file's contents.
+func (n *fsNode) addVersion(blocks *fileBlockList) {
+	v := blocks.firstBlock().Version
+	i := len(n.versions)
+	for i > 0 && n.versions[i-1].firstBlock().Version > v {
+		i--
+	}
+	n.versions = slices.Insert(n.versions, i, blocks)
+	if n.blocks == nil || n.firstBlock().Version < v {
+		n.blocks = blocks
+	}
 }
 
 func (n *fsNode) isDir() bool {
 	return n.blocks == nil || n.blocks.isDir()
 }
 
+func (n *fsNode) isSymlink() bool {
+	h := n.firstBlock()
+	return h != nil && h.Mode()&fs.ModeSymlink != 0
+}
+
+// dataNode returns the node containing the file contents for n.
+func (n *fsNode) dataNode() (*fsNode, error) {
+	h := n.firstBlock()
+	if h == nil || !h.isFileRef() {
+		return n, nil
+	}
+	if n.ref == nil || n.ref.blocks == nil {
+		return nil, fs.ErrNotExist
+	}
+	return n.ref, nil
+}
+
 func (n *fsNode) hasFileHash() bool {
 	return n.blocks != nil && n.blocks.hasFileHash()
 }
@@ -138,14 +181,111 @@ func (rfs *RarFS) openArchiveFile(blocks *fileBlockList) (fs.File, error) {
 	return rfs.vm.openArchiveFile(blocks)
 }
 
+// fileRef returns the node referenced by the hard link or file copy h,
+// or nil if h isn't one or the referenced file isn't in the archive.
+func (rfs *RarFS) fileRef(h *fileBlockHeader) *fsNode {
+	if !h.isFileRef() {
+		return nil
+	}
+	return rfs.ftree[archivedName(h.LinkTarget)]
+}
+
+// linkTarget returns the target of the symbolic link stored in node.
+func (rfs *RarFS) linkTarget(node *fsNode) (string, error) {
+	h := node.firstBlock()
+	if h.LinkType != LinkTypeNone {
+		return h.LinkTarget, nil
+	}
+	// RAR 4.x stores the link target as the file contents
+	f, err := rfs.openArchiveFile(node.blocks)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	return string(b), err
+}
+
+// resolveLink returns the name in the archive that target refers to when
+// it is the target of the symbolic link name.
+func resolveLink(name, target string) (string, error) {
+	target = strings.ReplaceAll(target, "\\", "/")
+	if path.IsAbs(target) || hasDriveLetter(target) {
+		return "", fs.ErrNotExist // target is outside the archive
+	}
+	name = path.Join(path.Dir(name), target)
+	if !fs.ValidPath(name) {
+		return "", fs.ErrNotExist
+	}
+	return name, nil
+}
+
+// hasDriveLetter returns if name starts with a Windows drive letter such as "C:".
+func hasDriveLetter(name string) bool {
+	if len(name) < 2 || name[1] != ':' {
+		return false
+	}
+	c := name[0] | 0x20 // lower case
+	return c >= 'a' && c <= 'z'
+}
+
+// archivedName returns name cleaned to the form used to look up files in the
+// archive, which is also the form used by the targets of hard links and file copies.
+func archivedName(name string) string {
+	return strings.TrimPrefix(path.Clean(name), "/")
+}
+
+// lookup returns the node for name. Symbolic links in the directories of name
+// are always followed, while a symbolic link in the last element of name is only
+// followed if follow is set.
+func (rfs *RarFS) lookup(name string, follow bool) (*fsNode, error) {
+	links := 0
+	dir := "." // resolved directory of the remaining elements
+	for {
+		elem, rest, _ := strings.Cut(name, "/")
+		fname := path.Join(dir, elem)
+		node := rfs.ftree[fname]
+		if node == nil {
+			return nil, fs.ErrNotExist
+		}
+		last := rest == ""
+		if !node.isSymlink() || (last && !follow) {
+			if last {
+				return node, nil
+			}
+			dir, name = fname, rest
+			continue
+		}
+		if links == maxSymlinks {
+			return nil, ErrTooManyLinks
+		}
+		links++
+		target, err := rfs.linkTarget(node)
+		if err != nil {
+			return nil, err
+		}
+		target, err = resolveLink(fname, target)
+		if err != nil {
+			return nil, err
+		}
+		// continue from the root with the resolved target
+		dir, name = ".", target
+		if !last {
+			name = path.Join(target, rest)
+		}
+	}
+}
+
 // Open opens the named file.
+// Symbolic links in any element of name are followed if their target is in the
+// archive, while hard links and file copies open the referenced file.
 func (rfs *RarFS) Open(name string) (fs.File, error) {
 	if !fs.ValidPath(name) {
 		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
 	}
-	node := rfs.ftree[name]
-	if node == nil {
-		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	node, err := rfs.lookup(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
 	}
 	if node.isDir() {
 		return &dirFile{
@@ -154,7 +294,11 @@ func (rfs *RarFS) Open(name string) (fs.File, error) {
 			files: node.dirEntryList(),
 		}, nil
 	}
-	f, err := rfs.openArchiveFile(node.blocks)
+	dnode, err := node.dataNode()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	f, err := rfs.openArchiveFile(dnode.blocks)
 	if err != nil {
 		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
 	}
@@ -181,13 +325,17 @@ func (rfs *RarFS) ReadFile(name string) ([]byte, error) {
 	if !fs.ValidPath(name) {
 		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
 	}
-	node := rfs.ftree[name]
-	if node == nil {
-		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrNotExist}
+	node, err := rfs.lookup(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
 	}
 	if node.isDir() {
 		return []byte{}, nil
 	}
+	node, err = node.dataNode()
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
 
 	f, err := rfs.openArchiveFile(node.blocks)
 	if err != nil {
@@ -231,18 +379,108 @@ func (rfs *RarFS) Check(name string) error {
 }
 */
 
+// Versions returns a FileInfo for each version of the named file stored in the
+// archive, sorted by version number. The version number is available from the
+// FileHeader returned by the FileInfo's Sys method. Open uses the highest version.
+func (rfs *RarFS) Versions(name string) ([]fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "versions", Path: name, Err: fs.ErrInvalid}
+	}
+	node, err := rfs.lookup(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "versions", Path: name, Err: err}
+	}
+	list := make([]fs.FileInfo, len(node.versions))
+	for i, blocks := range node.versions {
+		list[i] = fileInfo{h: blocks.firstBlock()}
+	}
+	return list, nil
+}
+
+// OpenVersion opens version v of the named file.
+// Hard links and file copies open the current version of the referenced file.
+func (rfs *RarFS) OpenVersion(name string, v int) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	node, err := rfs.lookup(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	for _, blocks := range node.versions {
+		h := blocks.firstBlock()
+		if h.Version != v {
+			continue
+		}
+		vnode := &fsNode{name: node.name, blocks: blocks, ref: rfs.fileRef(h)}
+		vnode, err = vnode.dataNode()
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		f, err := rfs.openArchiveFile(vnode.blocks)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return f, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
 // Stat returns a FileInfo describing the named file from the filesystem.
+// If the file is a symbolic link, the returned FileInfo describes the link's target.
 func (rfs *RarFS) Stat(name string) (fs.FileInfo, error) {
 	if !fs.ValidPath(name) {
 		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
 	}
-	node := rfs.ftree[name]
-	if node == nil {
-		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	node, err := rfs.lookup(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
 	}
 	return node.fileInfo(), nil
 }
 
+// Lstat returns a FileInfo describing the named file from the filesystem.
+// If the file is a symbolic link, the returned FileInfo describes the symbolic link.
+func (rfs *RarFS) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+	node, err := rfs.lookup(name, false)
+	if err != nil {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: err}
+	}
+	return node.fileInfo(), nil
+}
+
+// ReadLink returns the destination of the named symbolic link.
+func (rfs *RarFS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	node, err := rfs.lookup(name, false)
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	if !node.isSymlink() {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	target, err := rfs.linkTarget(node)
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	return target, nil
+}
+
+// ArchiveHeader returns the main archive header of the first volume opened.
+func (rfs *RarFS) ArchiveHeader() *ArchiveHeader {
+	return rfs.vm.archiveHeader()
+}
+
+// Comment returns the archive comment, or an empty string if the archive has none.
+func (rfs *RarFS) Comment() (string, error) {
+	return rfs.vm.comment()
+}
+
 // Sub returns an FS corresponding to the subtree rooted at fsys's dir.
 func (rfs *RarFS) Sub(dir string) (fs.FS, error) {
 	if dir == "." {
@@ -284,6 +522,7 @@ func listFileBlocks(name string, opts []Option) (*volumeManager, []*fileBlockLis
 		// Attempt parallel reading - will gracefully fall back to sequential on error
 		vm, fileBlocks, err := listFileBlocksParallel(name, opts)
 		if err == nil {
+			vm.solid = newSolidCache(fileBlocks)
 			return vm, fileBlocks, nil
 		}
 		// If parallel reading fails, continue with sequential fallback
@@ -298,15 +537,22 @@ func listFileBlocks(name string, opts []Option) (*volumeManager, []*fileBlockLis
 	defer v.Close()
 
 	fileBlocks := []*fileBlockList{}
+	lastBlocks := []*fileBlockHeader{} // last block read of each file
 	for {
 		blocks, err := pr.nextFile()
 		if err != nil {
 			if err == io.EOF {
+				for i, blocks := range fileBlocks {
+					// the service blocks of every file have now been read
+					blocks.copyServiceData(lastBlocks[i])
+				}
+				v.vm.solid = newSolidCache(fileBlocks)
 				return v.vm, fileBlocks, nil
 			}
 			return nil, nil, err
 		}
 		fileBlocks = append(fileBlocks, blocks)
+		lastBlocks = append(lastBlocks, pr.currFile())
 		if options.openCheck && blocks.hasFileHash() {
 			f, err := pr.newArchiveFile(blocks)
 			if err != nil {
@@ -332,19 +578,18 @@ func OpenFS(name string, opts ...Option) (*RarFS, error) {
 	}
 	for _, blocks := range fileBlocks {
 		h := blocks.firstBlock()
-		fname := strings.TrimPrefix(path.Clean(h.Name), "/")
+		fname := archivedName(h.Name)
 		if !fs.ValidPath(fname) {
 			return nil, fmt.Errorf("rardecode: archived file has invalid path: %s", fname)
 		}
 		node := rfs.ftree[fname]
 		if node != nil {
-			if node.blocks == nil || node.firstBlock().Version < h.Version {
-				node.blocks = blocks
-			}
+			node.addVersion(blocks)
 			continue
 		}
-		rfs.ftree[fname] = &fsNode{blocks: blocks}
-		prev := rfs.ftree[fname]
+		prev := &fsNode{}
+		prev.addVersion(blocks)
+		rfs.ftree[fname] = prev
 		// add parent file nodes
 		for fname != "." {
 			fname = path.Dir(fname)
@@ -360,5 +605,11 @@ func OpenFS(name string, opts ...Option) (*RarFS, error) {
 			prev = rfs.ftree[fname]
 		}
 	}
+	// link hard links and file copies to the files they reference
+	for _, node := range rfs.ftree {
+		if h := node.firstBlock(); h != nil {
+			node.ref = rfs.fileRef(h)
+		}
+	}
 	return rfs, nil
 }
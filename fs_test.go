@@ -0,0 +1,188 @@
+package rardecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestResolveLink(t *testing.T) {
+	tests := []struct {
+		name, target string
+		want         string
+		err          error
+	}{
+		{"testdata/proverb3.txt", "proverbs/extra/proverb3.txt", "testdata/proverbs/extra/proverb3.txt", nil},
+		{"a/b/link", "../c", "a/c", nil},
+		{"a/link", `dir\file`, "a/dir/file", nil},
+		{"link", "../outside", "", fs.ErrNotExist},
+		{"testdata/exist", "/target/does/not/exist", "", fs.ErrNotExist},
+		{"link", `\??\C:\Windows`, "", fs.ErrNotExist},
+		{"link", "c:file", "", fs.ErrNotExist},
+		{"a/link", "file:1", "a/file:1", nil},
+	}
+	for _, tt := range tests {
+		got, err := resolveLink(tt.name, tt.target)
+		if got != tt.want || err != tt.err {
+			t.Errorf("resolveLink(%q, %q) = %q, %v, want %q, %v", tt.name, tt.target, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestVersions(t *testing.T) {
+	file := func(name, contents string) []byte {
+		b := rar15Block(blockFile, blockHasData|fileVersion, rar15File(name, []byte(contents)), 0)
+		return append(b, contents...)
+	}
+	arc := []byte(sig15)
+	arc = append(arc, rar15Block(blockArc, 0, make([]byte, 6), 0)...)
+	arc = append(arc, file("a.txt;2", "second")...)
+	arc = append(arc, file("a.txt;1", "first")...)
+	arc = append(arc, file("b.txt", "b")...)
+	arc = append(arc, rar15Block(blockEnd, 0, nil, 0)...)
+	fsys := fstest.MapFS{"arc.rar": {Data: arc}}
+
+	rfs, err := OpenFS("arc.rar", FileSystem(fsys))
+	if err != nil {
+		t.Fatalf("OpenFS error = %v", err)
+	}
+	list, err := rfs.Versions("a.txt")
+	if err != nil {
+		t.Fatalf("Versions error = %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("Versions returned %d versions, want 2", len(list))
+	}
+	for i, want := range []string{"first", "second"} {
+		h := list[i].Sys().(*FileHeader)
+		if h.Version != i+1 || list[i].Size() != int64(len(want)) {
+			t.Errorf("version %d = %d with size %d, want %d with size %d", i, h.Version, list[i].Size(), i+1, len(want))
+		}
+		f, err := rfs.OpenVersion("a.txt", h.Version)
+		if err != nil {
+			t.Fatalf("OpenVersion(%d) error = %v", h.Version, err)
+		}
+		b, err := io.ReadAll(f)
+		f.Close()
+		if err != nil || string(b) != want {
+			t.Errorf("OpenVersion(%d) read %q, %v, want %q", h.Version, b, err, want)
+		}
+	}
+	// changing the FileHeader returned by Sys doesn't change the archive
+	h := list[1].Sys().(*FileHeader)
+	h.Name = "changed"
+	h.Hash[0] ^= 0xff
+	if h = list[1].Sys().(*FileHeader); h.Name != "a.txt" {
+		t.Errorf("Sys after change returned name %q, want %q", h.Name, "a.txt")
+	}
+	b, err := fs.ReadFile(rfs, "a.txt")
+	if err != nil || string(b) != "second" {
+		t.Errorf("ReadFile(a.txt) = %q, %v, want %q", b, err, "second")
+	}
+	if _, err = rfs.OpenVersion("a.txt", 3); err == nil {
+		t.Error("OpenVersion(a.txt, 3) succeeded for a missing version")
+	}
+	if _, err = rfs.Versions("c.txt"); err == nil {
+		t.Error("Versions(c.txt) succeeded for a missing file")
+	}
+
+	files, err := List("arc.rar", FileSystem(fsys), ParallelRead(true))
+	if err != nil {
+		t.Fatalf("List error = %v", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("List returned %d files, want 3", len(files))
+	}
+
+	// a file stored twice with the same version isn't dropped
+	dup := append([]byte(sig15), rar15Block(blockArc, 0, make([]byte, 6), 0)...)
+	dup = append(dup, file("b.txt", "b")...)
+	dup = append(dup, file("b.txt", "again")...)
+	dup = append(dup, rar15Block(blockEnd, 0, nil, 0)...)
+	fsys["dup.rar"] = &fstest.MapFile{Data: dup}
+	for _, opts := range [][]Option{nil, {ParallelRead(true)}} {
+		files, err = List("dup.rar", append(opts, FileSystem(fsys))...)
+		if err != nil {
+			t.Fatalf("List error = %v", err)
+		}
+		if len(files) != 2 || files[0].UnPackedSize != 1 || files[1].UnPackedSize != 5 {
+			t.Errorf("List(dup.rar, %d options) returned %d files, want both copies of b.txt", len(opts), len(files))
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	link := func(name string, ltype byte, target string) []byte {
+		rec := append([]byte{ltype, 0, byte(len(target))}, target...)
+		extra := binary.AppendUvarint(nil, uint64(len(rec)+1))
+		extra = append(append(extra, 5), rec...) // redirection record
+		return rar5Block(block5File, 0, rar5FileData(name, nil), extra, nil)
+	}
+	arc := []byte(sig50)
+	arc = append(arc, rar5Block(block5Arc, 0, []byte{0}, nil, nil)...)
+	arc = append(arc, rar5Block(block5File, 0, rar5FileData("dir/a.txt", []byte("hello")), nil, []byte("hello"))...)
+	arc = append(arc, link("dir/hard", LinkTypeHardLink, "./dir//a.txt")...)
+	arc = append(arc, link("dir/sym", LinkTypeUnixSymlink, "a.txt")...)
+	arc = append(arc, link("drive", LinkTypeWindowsSymlink, "C:a.txt")...)
+	arc = append(arc, link("linkdir", LinkTypeUnixSymlink, "dir")...)
+	arc = append(arc, link("loop1", LinkTypeUnixSymlink, "loop2")...)
+	arc = append(arc, link("loop2", LinkTypeUnixSymlink, "loop1")...)
+	arc = append(arc, rar5Block(block5End, 0, []byte{0}, nil, nil)...)
+	fsys := fstest.MapFS{"arc.rar": {Data: arc}}
+
+	// List and OpenFS resolve the hard link target the same way
+	files, err := List("arc.rar", FileSystem(fsys))
+	if err != nil || len(files) != 7 {
+		t.Fatalf("List = %d files, %v", len(files), err)
+	}
+	rc, err := files[1].Open()
+	if err != nil {
+		t.Fatalf("Open(%s) error = %v", files[1].Name, err)
+	}
+	b, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(b) != "hello" {
+		t.Errorf("List hard link read %q, %v, want %q", b, err, "hello")
+	}
+	rfs, err := OpenFS("arc.rar", FileSystem(fsys))
+	if err != nil {
+		t.Fatalf("OpenFS error = %v", err)
+	}
+	for _, name := range []string{"dir/hard", "dir/sym", "linkdir/a.txt", "linkdir/sym"} {
+		b, err = fs.ReadFile(rfs, name)
+		if err != nil || string(b) != "hello" {
+			t.Errorf("ReadFile(%s) = %q, %v, want %q", name, b, err, "hello")
+		}
+	}
+	if _, err = fs.ReadFile(rfs, "drive"); err == nil {
+		t.Error("ReadFile(drive) followed a link to a drive letter")
+	}
+	if fi, err := rfs.Lstat("linkdir/sym"); err != nil || fi.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("Lstat(linkdir/sym) = %v, %v, want a symbolic link", fi, err)
+	}
+	if _, err = rfs.Open("loop1/a.txt"); !errors.Is(err, ErrTooManyLinks) {
+		t.Errorf("Open(loop1/a.txt) error = %v, want %v", err, ErrTooManyLinks)
+	}
+
+	// symbolic links have no contents, the target is only in LinkTarget
+	r, err := NewReader(bytes.NewReader(arc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Next error = %v", err)
+		}
+		b, err = io.ReadAll(r)
+		if err != nil || int64(len(b)) != h.UnPackedSize {
+			t.Errorf("read %s = %q, %v, want %d bytes", h.Name, b, err, h.UnPackedSize)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"io/fs"
 	"math"
+	"slices"
 	"sync"
 	"time"
 )
@@ -24,6 +25,24 @@ const (
 	HostOSBeOS    = 6
 )
 
+// FileHeader HashType values
+const (
+	HashNone     = 0
+	HashCRC32    = 1
+	HashBLAKE2sp = 2
+	HashRAR14    = 3 // 16 bit checksum used by RAR 1.3 - 1.4 archives
+)
+
+// FileHeader LinkType values
+const (
+	LinkTypeNone           = 0
+	LinkTypeUnixSymlink    = 1
+	LinkTypeWindowsSymlink = 2
+	LinkTypeJunction       = 3
+	LinkTypeHardLink       = 4
+	LinkTypeFileCopy       = 5
+)
+
 const (
 	maxPassword = int(128)
 )
@@ -38,21 +57,69 @@ var (
 )
 
 // FileHeader represents a single file in a RAR archive.
+// NTFS security descriptors and RAR 3.x unix owner names are stored in service
+// blocks following the file data, so they are only set in the FileHeader's
+// returned by List, OpenFS and ListArchiveInfo.
 type FileHeader struct {
-	Name             string    // file name using '/' as the directory separator
-	IsDir            bool      // is a directory
-	Solid            bool      // is a solid file
-	Encrypted        bool      // file contents are encrypted
-	HeaderEncrypted  bool      // file header is encrypted
-	HostOS           byte      // Host OS the archive was created on
-	Attributes       int64     // Host OS specific file attributes
-	PackedSize       int64     // packed file size (or first block if the file spans volumes)
-	UnPackedSize     int64     // unpacked file size
-	UnKnownSize      bool      // unpacked file size is not known
-	ModificationTime time.Time // modification time (non-zero if set)
-	CreationTime     time.Time // creation time (non-zero if set)
-	AccessTime       time.Time // access time (non-zero if set)
-	Version          int       // file version
+	Name               string        // file name using '/' as the directory separator
+	IsDir              bool          // is a directory
+	Solid              bool          // is a solid file
+	Encrypted          bool          // file contents are encrypted
+	HeaderEncrypted    bool          // file header is encrypted
+	HostOS             byte          // Host OS the archive was created on
+	Attributes         int64         // Host OS specific file attributes
+	PackedSize         int64         // packed file size (or first block if the file spans volumes)
+	UnPackedSize       int64         // unpacked file size
+	UnKnownSize        bool          // unpacked file size is not known
+	ModificationTime   time.Time     // modification time (non-zero if set)
+	CreationTime       time.Time     // creation time (non-zero if set)
+	AccessTime         time.Time     // access time (non-zero if set)
+	Version            int           // file version
+	HashType           int           // type of file checksum (HashNone, HashCRC32, HashBLAKE2sp or HashRAR14)
+	Hash               []byte        // expected file checksum (HMAC of the checksum if file uses MAC)
+	LinkType           int           // type of link (LinkTypeNone if the file is not a link)
+	LinkTarget         string        // link target, or archived name of the referenced file for hard links and file copies
+	LinkTargetIsDir    bool          // link target is a directory
+	UserName           string        // name of the file owner (if HasUserName is set)
+	GroupName          string        // name of the file group (if HasGroupName is set)
+	UID                int           // numeric user ID of the file owner (if HasUID is set)
+	GID                int           // numeric group ID of the file group (if HasGID is set)
+	HasUserName        bool          // UserName is set
+	HasGroupName       bool          // GroupName is set
+	HasUID             bool          // UID is set
+	HasGID             bool          // GID is set
+	SecurityDescriptor []byte        // NTFS security descriptor in self-relative format (nil if not present or not read)
+	OwnerSID           string        // owner SID from SecurityDescriptor (empty if not present)
+	GroupSID           string        // primary group SID from SecurityDescriptor (empty if not present)
+	ExtraRecords       []ExtraRecord // raw extra records of the file header, including unknown types (RAR 5 only)
+}
+
+// clone returns a copy of f that doesn't share any slices with f.
+func (f *FileHeader) clone() *FileHeader {
+	h := *f
+	h.Hash = slices.Clone(f.Hash)
+	h.SecurityDescriptor = slices.Clone(f.SecurityDescriptor)
+	if f.ExtraRecords != nil {
+		h.ExtraRecords = make([]ExtraRecord, len(f.ExtraRecords))
+		for i, e := range f.ExtraRecords {
+			h.ExtraRecords[i] = ExtraRecord{Type: e.Type, Data: slices.Clone(e.Data)}
+		}
+	}
+	return &h
+}
+
+// isSymlink returns if the file is a symbolic link or junction stored in a redirection record.
+func (f *FileHeader) isSymlink() bool {
+	switch f.LinkType {
+	case LinkTypeUnixSymlink, LinkTypeWindowsSymlink, LinkTypeJunction:
+		return true
+	}
+	return false
+}
+
+// isFileRef returns if the file contents are a reference to another file in the archive.
+func (f *FileHeader) isFileRef() bool {
+	return f.LinkType == LinkTypeHardLink || f.LinkType == LinkTypeFileCopy
 }
 
 // Mode returns an fs.FileMode for the file, calculated from the Attributes field.
@@ -62,6 +129,9 @@ func (f *FileHeader) Mode() fs.FileMode {
 	if f.IsDir {
 		m = fs.ModeDir
 	}
+	if f.isSymlink() {
+		m |= fs.ModeSymlink
+	}
 	if f.HostOS == HostOSWindows {
 		if f.IsDir {
 			m |= 0777
@@ -122,9 +192,61 @@ type fileCloser struct {
 	io.Closer
 }
 
+// fileSeekCloser is a file that can be seeked. ReadAt reads from a second copy
+// of the file, so that it doesn't change the offset used by Read and Seek.
 type fileSeekCloser struct {
 	archiveFileSeeker
 	io.Closer
+	vm     *volumeManager
+	blocks *fileBlockList
+
+	mu   sync.Mutex // protects at and atCl
+	at   archiveFileSeeker
+	atCl io.Closer
+}
+
+// ReadAt reads len(p) bytes from the file starting at offset off.
+// Concurrent calls to ReadAt are serialized.
+func (f *fileSeekCloser) ReadAt(p []byte, off int64) (int, error) {
+	if h := f.blocks.firstBlock(); !h.UnKnownSize && off >= h.UnPackedSize {
+		return 0, io.EOF
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.at == nil {
+		r, cl, err := f.vm.openFileReader(f.blocks)
+		if err != nil {
+			return 0, err
+		}
+		sr, ok := r.(archiveFileSeeker)
+		if !ok {
+			cl.Close()
+			return 0, fs.ErrInvalid
+		}
+		f.at, f.atCl = sr, cl
+	}
+	_, err := f.at.Seek(off, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(f.at, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *fileSeekCloser) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := f.Closer.Close()
+	if f.atCl != nil {
+		if cerr := f.atCl.Close(); err == nil {
+			err = cerr
+		}
+		f.at, f.atCl = nil, nil
+	}
+	return err
 }
 
 type errorFile struct {
@@ -137,6 +259,27 @@ func (ef *errorFile) Read(p []byte) (int, error)                   { return 0, e
 func (ef *errorFile) WriteTo(w io.Writer) (int64, error)           { return 0, ef.err }
 func (ef *errorFile) writeToN(w io.Writer, n int64) (int64, error) { return 0, ef.err }
 
+// bytesFile is an archiveFile whose contents are read from memory.
+type bytesFile struct {
+	archiveFile
+	r *bytes.Reader
+}
+
+func (bf *bytesFile) Read(p []byte) (int, error)         { return bf.r.Read(p) }
+func (bf *bytesFile) ReadByte() (byte, error)            { return bf.r.ReadByte() }
+func (bf *bytesFile) WriteTo(w io.Writer) (int64, error) { return bf.r.WriteTo(w) }
+
+func (bf *bytesFile) writeToN(w io.Writer, n int64) (int64, error) {
+	if n < 0 {
+		return bf.r.WriteTo(w)
+	}
+	return io.CopyN(w, bf.r, n)
+}
+
+func newBytesFile(f archiveFile, b []byte) *bytesFile {
+	return &bytesFile{archiveFile: f, r: bytes.NewReader(b)}
+}
+
 type fileBlockList struct {
 	mu     sync.RWMutex
 	blocks []*fileBlockHeader
@@ -171,6 +314,38 @@ func (fl *fileBlockList) addBlock(h *fileBlockHeader) {
 	defer fl.mu.Unlock()
 	if len(fl.blocks) == h.blocknum {
 		fl.blocks = append(fl.blocks, h)
+		if h.last && h.blocknum > 0 {
+			// checksum for the whole file is stored in the last block
+			f := fl.blocks[0]
+			f.HashType = h.HashType
+			f.Hash = h.Hash
+		}
+	}
+}
+
+// copyServiceData copies the file metadata read from the service blocks
+// following last, the last block read of the file, to the first block.
+func (fl *fileBlockList) copyServiceData(last *fileBlockHeader) {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	f := fl.blocks[0]
+	h := last.meta
+	if h == nil {
+		return
+	}
+	f.services = true
+	if h.SecurityDescriptor != nil {
+		f.SecurityDescriptor = h.SecurityDescriptor
+		f.OwnerSID = h.OwnerSID
+		f.GroupSID = h.GroupSID
+	}
+	if h.HasUserName {
+		f.UserName = h.UserName
+		f.HasUserName = true
+	}
+	if h.HasGroupName {
+		f.GroupName = h.GroupName
+		f.HasGroupName = true
 	}
 }
 
@@ -382,6 +557,13 @@ func (f *packedFileReader) WriteTo(w io.Writer) (int64, error) {
 
 func (pr *packedFileReader) newArchiveFileFrom(r archiveFile, blocks *fileBlockList) (archiveFile, error) {
 	h := blocks.firstBlock()
+	if pr.blocks == blocks && pr.h != h && pr.h.last && pr.v.canSeek() {
+		// nextFile read ahead to the last block, return to the start of the file
+		err := pr.v.openBlock(h.volnum, h.dataOff, h.PackedSize)
+		if err != nil {
+			return nil, err
+		}
+	}
 	err := pr.init(blocks)
 	if err != nil {
 		return nil, err
@@ -394,6 +576,11 @@ func (pr *packedFileReader) newArchiveFileFrom(r archiveFile, blocks *fileBlockL
 		}
 		return &errorFile{archiveFile: r, err: err}, nil
 	}
+	// links stored in a redirection record have no packed data, the
+	// target of a symbolic link is only returned in LinkTarget
+	if h.isSymlink() || h.isFileRef() {
+		return newBytesFile(r, nil), nil
+	}
 	if h.Encrypted {
 		if h.key == nil {
 			return &errorFile{archiveFile: r, err: ErrArchivedFileEncrypted}, nil
@@ -610,7 +797,12 @@ func (l *limitedReadSeeker) Seek(offset int64, whence int) (int64, error) {
 	if offset < 0 || offset > l.size {
 		return 0, fs.ErrInvalid
 	}
-	return l.sr.Seek(offset, io.SeekStart)
+	n, err := l.sr.Seek(offset, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	l.offset = n
+	return n, nil
 }
 
 func newLimitedReader(f archiveFile, size int64) archiveFile {
@@ -702,8 +894,34 @@ func (cr *checksumReader) WriteTo(w io.Writer) (int64, error) {
 	return n, nil
 }
 
-func newChecksumReader(f archiveFile, h hash.Hash, success func()) *checksumReader {
-	return &checksumReader{archiveFile: f, hash: h, success: success}
+// checksumReadSeeker is a checksumReader that can be seeked. The checksum can
+// only be checked when the file is read from the start, so seeking anywhere else
+// disables the check until the file is seeked back to the start.
+type checksumReadSeeker struct {
+	checksumReader
+	sr io.Seeker
+}
+
+func (cr *checksumReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	n, err := cr.sr.Seek(offset, whence)
+	if err != nil || (offset == 0 && whence == io.SeekCurrent) {
+		return n, err
+	}
+	cr.hash.Reset()
+	if n == 0 {
+		cr.eofErr = nil
+	} else {
+		cr.eofErr = io.EOF
+	}
+	return n, nil
+}
+
+func newChecksumReader(f archiveFile, h hash.Hash, success func()) archiveFile {
+	cr := checksumReader{archiveFile: f, hash: h, success: success}
+	if sr, ok := f.(io.Seeker); ok {
+		return &checksumReadSeeker{checksumReader: cr, sr: sr}
+	}
+	return &cr
 }
 
 // Reader provides sequential access to files in a RAR archive.
@@ -764,6 +982,17 @@ func (rc *ReadCloser) Volumes() []string {
 	return rc.vm.Files()
 }
 
+// ArchiveHeader returns the main archive header of the first volume opened.
+func (rc *ReadCloser) ArchiveHeader() *ArchiveHeader {
+	return rc.vm.archiveHeader()
+}
+
+// Comment returns the archive comment, or an empty string if the archive has none.
+// The comment is read from the first volume each time Comment is called.
+func (rc *ReadCloser) Comment() (string, error) {
+	return rc.vm.comment()
+}
+
 // OpenReader opens a RAR archive specified by the name and returns a ReadCloser.
 func OpenReader(name string, opts ...Option) (*ReadCloser, error) {
 	options := getOptions(opts)
@@ -780,17 +1009,53 @@ func OpenReader(name string, opts ...Option) (*ReadCloser, error) {
 type File struct {
 	FileHeader
 	blocks *fileBlockList
+	ref    *fileBlockList // referenced file for hard links and file copies
 	vm     *volumeManager
 }
 
 // Open returns an io.ReadCloser that provides access to the File's contents.
-// Open is not supported on Solid File's as their contents depend on the decoding
-// of the preceding files in the archive. Use OpenReader and Next to access Solid file
-// contents instead.
+// The contents of Solid File's depend on the decoding of the preceding files in
+// the archive, which are decoded again unless the previous file in the archive was
+// opened and read to the end. Use OpenReader and Next to read every file of a
+// solid archive more efficiently.
+// Opening a hard link or file copy returns the contents of the referenced file.
+// The returned io.ReadCloser also implements io.Seeker and io.ReaderAt. Seeking
+// backwards in a compressed file decodes the file again from its start.
 func (f *File) Open() (io.ReadCloser, error) {
+	if f.isFileRef() {
+		if f.ref == nil {
+			return nil, fs.ErrNotExist
+		}
+		return f.vm.openArchiveFile(f.ref)
+	}
 	return f.vm.openArchiveFile(f.blocks)
 }
 
+// OpenReaderAt returns a FileReaderAt and the size of the File for concurrent
+// random access to its contents, reading directly from the volume files.
+// Only stored files that aren't encrypted are supported, other files return
+// ErrReaderAtUnsupported. The FileReaderAt must be closed when no longer needed.
+func (f *File) OpenReaderAt() (*FileReaderAt, int64, error) {
+	blocks := f.blocks
+	if f.isFileRef() {
+		if f.ref == nil {
+			return nil, 0, fs.ErrNotExist
+		}
+		blocks = f.ref
+	}
+	r, err := f.vm.openReaderAt(blocks)
+	if err != nil {
+		return nil, 0, err
+	}
+	return r, r.Size(), nil
+}
+
+// Streams returns the NTFS alternate data streams stored with the File.
+// The streams are read from the archive each time Streams is called.
+func (f *File) Streams() ([]*Stream, error) {
+	return f.vm.streams(f.blocks)
+}
+
 // List returns a list of File's in the RAR archive specified by name.
 func List(name string, opts ...Option) ([]*File, error) {
 	vm, fileBlocks, err := listFileBlocks(name, opts)
@@ -798,6 +1063,7 @@ func List(name string, opts ...Option) ([]*File, error) {
 		return nil, err
 	}
 	var fl []*File
+	names := map[string]*fileBlockList{}
 	for _, blocks := range fileBlocks {
 		h := blocks.firstBlock()
 		f := &File{
@@ -806,6 +1072,12 @@ func List(name string, opts ...Option) ([]*File, error) {
 			vm:         vm,
 		}
 		fl = append(fl, f)
+		names[archivedName(h.Name)] = blocks
+	}
+	for _, f := range fl {
+		if f.isFileRef() {
+			f.ref = names[archivedName(f.LinkTarget)]
+		}
 	}
 	return fl, nil
 }
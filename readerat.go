@@ -0,0 +1,221 @@
+package rardecode
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+	"sync"
+)
+
+const defaultReaderAtVolumes = 10 // default maximum number of volume files kept open by a FileReaderAt
+
+var (
+	ErrReaderAtUnsupported = errors.New("rardecode: ReaderAt requires a stored unencrypted file")
+	ErrVolumeNotReaderAt   = errors.New("rardecode: volume file doesn't implement io.ReaderAt")
+)
+
+// volumeHandle is an open volume file shared by concurrent reads.
+type volumeHandle struct {
+	f       fs.File
+	ra      io.ReaderAt
+	refs    int    // number of reads using the handle
+	lastUse uint64 // pool clock value when the handle was last released
+}
+
+// volumeHandlePool keeps up to max volume files open for reading.
+// Handles in use are never closed, so more than max files may be open while
+// there are more concurrent reads from different volumes. The extra files are
+// closed when the reads finish.
+type volumeHandlePool struct {
+	vm  *volumeManager
+	max int
+
+	mu      sync.Mutex
+	handles map[int]*volumeHandle
+	clock   uint64
+	closed  bool
+}
+
+// evict closes the least recently used handle that isn't in use.
+func (p *volumeHandlePool) evict() {
+	volnum := -1
+	for n, h := range p.handles {
+		if h.refs == 0 && (volnum < 0 || h.lastUse < p.handles[volnum].lastUse) {
+			volnum = n
+		}
+	}
+	if volnum >= 0 {
+		p.handles[volnum].f.Close()
+		delete(p.handles, volnum)
+	}
+}
+
+// get returns a handle for volume volnum, opening the volume file if needed.
+func (p *volumeHandlePool) get(volnum int) (*volumeHandle, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, fs.ErrClosed
+	}
+	if h := p.handles[volnum]; h != nil {
+		h.refs++
+		return h, nil
+	}
+	if len(p.handles) >= p.max {
+		p.evict()
+	}
+	f, err := p.vm.openVolumeFile(volnum)
+	if err != nil {
+		return nil, err
+	}
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		f.Close()
+		return nil, ErrVolumeNotReaderAt
+	}
+	h := &volumeHandle{f: f, ra: ra, refs: 1}
+	p.handles[volnum] = h
+	return h, nil
+}
+
+// release returns a handle obtained from get to the pool. The handle is closed
+// if the pool is closed or has more than max handles open.
+func (p *volumeHandlePool) release(h *volumeHandle) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h.refs--
+	p.clock++
+	h.lastUse = p.clock
+	if h.refs > 0 {
+		return
+	}
+	if p.closed {
+		h.f.Close()
+	} else if len(p.handles) > p.max {
+		p.evict()
+	}
+}
+
+func (p *volumeHandlePool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	var err error
+	for volnum, h := range p.handles {
+		if h.refs == 0 {
+			if cerr := h.f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		delete(p.handles, volnum)
+	}
+	return err
+}
+
+// filePart is the data of a file stored in a single volume.
+type filePart struct {
+	volnum  int
+	dataOff int64 // offset of the data in the volume file
+	off     int64 // offset of the data in the file
+	size    int64
+}
+
+// FileReaderAt provides concurrent random access to the contents of a stored file,
+// reading directly from the volume files that contain it. Volume files are kept
+// open until they are evicted from its pool of open files, or Close is called.
+type FileReaderAt struct {
+	parts []filePart
+	size  int64
+	pool  *volumeHandlePool
+}
+
+// Size returns the size of the file.
+func (r *FileReaderAt) Size() int64 { return r.size }
+
+// readPart reads p from part starting at offset off in the part.
+func (r *FileReaderAt) readPart(part filePart, p []byte, off int64) (int, error) {
+	h, err := r.pool.get(part.volnum)
+	if err != nil {
+		return 0, err
+	}
+	defer r.pool.release(h)
+	n, err := h.ra.ReadAt(p, part.dataOff+off)
+	if err == io.EOF && n < len(p) {
+		// volume ended before the end of the part
+		err = ErrUnexpectedArcEnd
+	} else if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt implements io.ReaderAt. It is safe to call ReadAt concurrently.
+func (r *FileReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fs.ErrInvalid
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	var err error
+	if int64(len(p)) > r.size-off {
+		p = p[:r.size-off]
+		err = io.EOF
+	}
+	// find the first part containing off
+	i := sort.Search(len(r.parts), func(i int) bool {
+		return r.parts[i].off+r.parts[i].size > off
+	})
+	var n int
+	for n < len(p) && i < len(r.parts) {
+		part := r.parts[i]
+		b := p[n:]
+		pos := off + int64(n) - part.off
+		if int64(len(b)) > part.size-pos {
+			b = b[:part.size-pos]
+		}
+		l, perr := r.readPart(part, b, pos)
+		n += l
+		if perr != nil {
+			return n, perr
+		}
+		i++
+	}
+	return n, err
+}
+
+// Close closes the volume files opened by the FileReaderAt.
+func (r *FileReaderAt) Close() error {
+	return r.pool.close()
+}
+
+// openReaderAt returns a FileReaderAt for the stored file in blocks.
+func (vm *volumeManager) openReaderAt(blocks *fileBlockList) (*FileReaderAt, error) {
+	blocks.mu.RLock()
+	list := blocks.blocks
+	blocks.mu.RUnlock()
+	h := list[0]
+	if h.decVer != 0 || h.Encrypted || h.IsDir || h.isSymlink() || h.isFileRef() {
+		return nil, ErrReaderAtUnsupported
+	}
+	if !list[len(list)-1].last {
+		return nil, ErrUnexpectedArcEnd
+	}
+	max := vm.opt.maxConcurrentVolumes
+	if max <= 0 {
+		max = defaultReaderAtVolumes
+	}
+	r := &FileReaderAt{
+		pool: &volumeHandlePool{vm: vm, max: max, handles: map[int]*volumeHandle{}},
+	}
+	for _, b := range list {
+		r.parts = append(r.parts, filePart{volnum: b.volnum, dataOff: b.dataOff, off: r.size, size: b.PackedSize})
+		r.size += b.PackedSize
+	}
+	if !h.UnKnownSize && h.UnPackedSize < r.size {
+		// ignore any padding after the file contents
+		r.size = h.UnPackedSize
+	}
+	return r, nil
+}
@@ -0,0 +1,83 @@
+package rardecode
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFileReaderAt(t *testing.T) {
+	contents := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	vols := multiVolumeArchive(contents, 3)
+	fsys := fstest.MapFS{}
+	for i, v := range vols {
+		fsys[fmt.Sprintf("arc.part%d.rar", i+1)] = &fstest.MapFile{Data: v}
+	}
+	files, err := List("arc.part1.rar", FileSystem(fsys), MaxConcurrentVolumes(2))
+	if err != nil {
+		t.Fatalf("List error = %v", err)
+	}
+	r, size, err := files[0].OpenReaderAt()
+	if err != nil {
+		t.Fatalf("OpenReaderAt error = %v", err)
+	}
+	defer r.Close()
+	if size != int64(len(contents)) {
+		t.Fatalf("OpenReaderAt size = %d, want %d", size, len(contents))
+	}
+
+	// concurrent reads crossing volume boundaries
+	var wg sync.WaitGroup
+	for off := 0; off < len(contents); off++ {
+		wg.Add(1)
+		go func(off int) {
+			defer wg.Done()
+			b := make([]byte, 15)
+			n, err := r.ReadAt(b, int64(off))
+			want := contents[off:min(off+len(b), len(contents))]
+			wantErr := error(nil)
+			if len(want) < len(b) {
+				wantErr = io.EOF
+			}
+			if err != wantErr || !bytes.Equal(b[:n], want) {
+				t.Errorf("ReadAt(%d) = %q, %v, want %q, %v", off, b[:n], err, want, wantErr)
+			}
+		}(off)
+	}
+	wg.Wait()
+	if n := len(r.pool.handles); n > 2 {
+		t.Errorf("%d volumes open, want at most 2", n)
+	}
+	if _, err = r.ReadAt(make([]byte, 1), size); err != io.EOF {
+		t.Errorf("ReadAt(%d) error = %v, want EOF", size, err)
+	}
+}
+
+func TestMultiVolumeReader(t *testing.T) {
+	contents := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	vols := multiVolumeArchive(contents, 3)
+	fsys := fstest.MapFS{}
+	for i, v := range vols {
+		fsys[fmt.Sprintf("arc.part%d.rar", i+1)] = &fstest.MapFile{Data: v}
+	}
+	rc, err := OpenReader("arc.part1.rar", FileSystem(fsys))
+	if err != nil {
+		t.Fatalf("OpenReader error = %v", err)
+	}
+	defer rc.Close()
+	h, err := rc.Next()
+	if err != nil {
+		t.Fatalf("Next error = %v", err)
+	}
+	// Next reads ahead to the last block of the file, reading must start from the first
+	b, err := io.ReadAll(rc)
+	if err != nil || !bytes.Equal(b, contents) {
+		t.Errorf("read %s = %q, %v, want %q", h.Name, b, err, contents)
+	}
+	if _, err = rc.Next(); err != io.EOF {
+		t.Errorf("Next error = %v, want %v", err, io.EOF)
+	}
+}
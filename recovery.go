@@ -0,0 +1,310 @@
+package rardecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+)
+
+const (
+	recSectorSize    = 512        // size of a sector protected by a recovery record
+	recScanSize      = 0x10000    // size of chunks read when searching for a recovery record
+	recMaxHeaderSize = 512        // maximum size of a recovery record block header
+	protectMark      = "Protect!" // RAR 2.x protect block marker
+	recoveryMark     = "Protect+" // RAR 3.x recovery record service block marker
+)
+
+var (
+	ErrNoRecoveryRecord    = errors.New("rardecode: archive has no recovery record")
+	ErrUnsupportedRecovery = errors.New("rardecode: unsupported recovery record format")
+	ErrCorruptRecovery     = errors.New("rardecode: corrupt recovery record")
+	ErrRepairFailed        = errors.New("rardecode: too many damaged sectors to repair volume")
+)
+
+// RecoveryInfo is the result of checking an archive volume against its recovery record.
+type RecoveryInfo struct {
+	Sectors     int64 // number of sectors protected by the recovery record
+	BadSectors  int   // number of damaged sectors
+	Recoverable int   // number of damaged sectors that can be reconstructed
+}
+
+// recoveryChecker is a recovery record that can check the protected part of a volume.
+type recoveryChecker interface {
+	// protected returns the size of the protected part of the volume and
+	// the size of the sectors it is checked in.
+	protected() (size, sectorSize int64)
+	// check reads the protected part of the volume from r, returning which
+	// damaged sectors can be reconstructed and their contents by sector number.
+	check(r io.Reader) (*RecoveryInfo, map[int64][]byte, error)
+}
+
+// recoveryRecord is a RAR 2.x - 4.x recovery record.
+// The protected part of the volume is split into 512 byte sectors. Each sector
+// has a 16 bit checksum, and is XORed into the parity sector selected by its
+// sector number modulo the number of parity sectors. A single damaged sector
+// in each parity group can be reconstructed.
+type recoveryRecord struct {
+	size   int64  // size of the protected part of the volume
+	blocks int64  // number of protected sectors
+	tags   []byte // checksum of each protected sector
+	parity []byte // parity sectors
+}
+
+func (rr *recoveryRecord) protected() (int64, int64) { return rr.size, recSectorSize }
+
+// sectorTag returns the checksum of a protected sector.
+func sectorTag(b []byte) uint16 {
+	return uint16(crc32.ChecksumIEEE(b))
+}
+
+// parseRecoveryData returns the number of parity sectors and protected sectors
+// from the service data of a recovery record block.
+func parseRecoveryData(b readBuf) (int, int64, error) {
+	switch {
+	case len(b) >= 15 && string(b[7:15]) == protectMark:
+		// RAR 2.x protect block
+		_ = b.byte() // version
+		sectors := int(b.uint16())
+		return sectors, int64(b.uint32()), nil
+	case len(b) >= 20 && string(b[:8]) == recoveryMark:
+		_ = b.bytes(8)
+		sectors := int(b.uint32())
+		return sectors, int64(b.uint64()), nil
+	}
+	return 0, 0, ErrUnsupportedRecovery
+}
+
+// recoveryBlockOffsets returns the possible offsets of the start of a recovery
+// record block from the marker found at the end of its header.
+func recoveryBlockOffsets(mark []byte) []int64 {
+	switch string(mark) {
+	case protectMark:
+		return []int64{18} // RAR 2.x protect block
+	case recoveryMark:
+		// RAR 3.x service block named RR, with and without the high 32 bits of the data size
+		return []int64{34, 42}
+	}
+	return nil
+}
+
+// findRecoveryRecord searches backwards from the end of a RAR 2.x - 4.x volume
+// of the given size for its recovery record. Only the recovery record block is
+// read, so it is found even when the block headers before it are damaged.
+func findRecoveryRecord(ra io.ReaderAt, size int64) (*recoveryRecord, error) {
+	const prefix = "Protect" // common prefix of protectMark and recoveryMark
+	buf := make([]byte, recScanSize+len(recoveryMark)-1)
+	for end := size; end > 0; {
+		start := max(0, end-recScanSize)
+		n, err := ra.ReadAt(buf[:min(int64(len(buf)), size-start)], start)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		b := buf[:n]
+		for i := min(len(b), int(end-start)+len(prefix)-1); ; {
+			i = bytes.LastIndex(b[:i], []byte(prefix))
+			if i < 0 {
+				break
+			}
+			if i+len(recoveryMark) > len(b) {
+				continue
+			}
+			for _, d := range recoveryBlockOffsets(b[i : i+len(recoveryMark)]) {
+				rr, err := readRecoveryBlock(ra, size, start+int64(i)-d)
+				if err == nil {
+					return rr, nil
+				}
+			}
+		}
+		end = start
+	}
+	return nil, ErrNoRecoveryRecord
+}
+
+// readRecoveryBlock reads the recovery record block at offset off in a
+// RAR 2.x - 4.x volume of the given size.
+func readRecoveryBlock(ra io.ReaderAt, size, off int64) (*recoveryRecord, error) {
+	if off < 0 {
+		return nil, ErrCorruptRecovery
+	}
+	buf := make([]byte, min(recMaxHeaderSize, size-off))
+	n, err := ra.ReadAt(buf, off)
+	if n < len(buf) {
+		return nil, err
+	}
+	a := new(archive15)
+	h, err := a.readBlockHeader(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	var f *fileBlockHeader
+	switch h.htype {
+	case blockProtect:
+		f = a.parseProtectBlock(h)
+	case blockService:
+		f, err = a.parseFileHeader(h)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if f == nil || f.Name != serviceRecovery {
+		return nil, ErrCorruptRecovery
+	}
+	sectors, blocks, err := parseRecoveryData(f.subData)
+	if err != nil {
+		return nil, err
+	}
+	dataOff := off + int64(len(h.raw))
+	if sectors <= 0 || blocks != (off+recSectorSize-1)/recSectorSize ||
+		blocks*2+int64(sectors)*recSectorSize != f.PackedSize || dataOff+f.PackedSize > size {
+		return nil, ErrCorruptRecovery
+	}
+	b := make([]byte, f.PackedSize)
+	n, err = ra.ReadAt(b, dataOff)
+	if n < len(b) {
+		return nil, err
+	}
+	return &recoveryRecord{size: off, blocks: blocks, tags: b[:blocks*2], parity: b[blocks*2:]}, nil
+}
+
+// readRecoveryRecord reads the recovery record of the volume opened as f.
+func readRecoveryRecord(opt *options, f fs.File) (recoveryChecker, error) {
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		return nil, ErrVolumeNotReaderAt
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	br, err := newBufVolumeReader(f, opt.bsize)
+	if err != nil {
+		return nil, err
+	}
+	switch br.ver {
+	case archiveVersion15:
+		return findRecoveryRecord(ra, fi.Size())
+	case archiveVersion50:
+		return findRecoveryRecord5(br, ra, fi.Size(), opt)
+	}
+	return nil, ErrNoRecoveryRecord
+}
+
+// check reads the protected sectors of a volume from r, returning which damaged
+// sectors can be reconstructed and their contents. The last sector is padded
+// with zeros if it ends before the recovery record.
+func (rr *recoveryRecord) check(r io.Reader) (*RecoveryInfo, map[int64][]byte, error) {
+	info := &RecoveryInfo{Sectors: rr.blocks}
+	n := int64(len(rr.parity) / recSectorSize)
+	acc := make([]byte, len(rr.parity)) // XOR of all sectors in each parity group
+	bad := map[int64][]byte{}
+	groups := make([]int, n) // number of damaged sectors in each parity group
+	r = io.LimitReader(r, rr.size)
+	buf := make([]byte, recSectorSize)
+	for i := int64(0); i < rr.blocks; i++ {
+		l, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, nil, err
+		}
+		clear(buf[l:])
+		g := i % n
+		p := acc[g*recSectorSize : (g+1)*recSectorSize]
+		for j := range p {
+			p[j] ^= buf[j]
+		}
+		if sectorTag(buf) != binary.LittleEndian.Uint16(rr.tags[i*2:]) {
+			bad[i] = bytes.Clone(buf)
+			groups[g]++
+		}
+	}
+	info.BadSectors = len(bad)
+	fixed := map[int64][]byte{}
+	for i, b := range bad {
+		g := i % n
+		if groups[g] != 1 {
+			continue
+		}
+		p := rr.parity[g*recSectorSize : (g+1)*recSectorSize]
+		a := acc[g*recSectorSize : (g+1)*recSectorSize]
+		for j := range b {
+			b[j] ^= p[j] ^ a[j]
+		}
+		if sectorTag(b) == binary.LittleEndian.Uint16(rr.tags[i*2:]) {
+			fixed[i] = b
+		}
+	}
+	info.Recoverable = len(fixed)
+	return info, fixed, nil
+}
+
+// checkVolume checks the volume name against its recovery record.
+func checkVolume(name string, opts []Option) (recoveryChecker, *RecoveryInfo, map[int64][]byte, error) {
+	options := getOptions(opts)
+	f, err := options.fs.Open(name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer f.Close()
+	rr, err := readRecoveryRecord(options, f)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	size, _ := rr.protected()
+	info, fixed, err := rr.check(io.NewSectionReader(f.(io.ReaderAt), 0, size))
+	return rr, info, fixed, err
+}
+
+// VerifyRecovery checks the archive volume name against its recovery record,
+// reporting how many sectors are damaged and how many of them can be repaired.
+// The volume file must implement io.ReaderAt.
+//
+// RAR 2.x - 4.x recovery records are found by searching back from the end of
+// the volume, so damaged block headers before them don't prevent the check.
+// RAR 5 recovery records are found using the locator in the main archive header,
+// or by reading the volume's blocks. Their layout isn't documented by RarLab,
+// so only records in the layout described by recoveryRecord5 are supported,
+// and ErrUnsupportedRecovery is returned for others.
+func VerifyRecovery(name string, opts ...Option) (*RecoveryInfo, error) {
+	_, info, _, err := checkVolume(name, opts)
+	return info, err
+}
+
+// Repair writes a copy of the archive volume name to w, with any damaged sectors
+// reconstructed using the volume's recovery record. If not all damaged sectors
+// can be reconstructed, nothing is written and ErrRepairFailed is returned along
+// with the result of the check. Truncated volumes are not extended.
+func Repair(name string, w io.Writer, opts ...Option) (*RecoveryInfo, error) {
+	rr, info, fixed, err := checkVolume(name, opts)
+	if err != nil {
+		return info, err
+	}
+	if info.BadSectors > info.Recoverable {
+		return info, ErrRepairFailed
+	}
+	f, err := getOptions(opts).fs.Open(name)
+	if err != nil {
+		return info, err
+	}
+	defer f.Close()
+	size, sectorSize := rr.protected()
+	buf := make([]byte, sectorSize)
+	for off := int64(0); off < size; off += sectorSize {
+		l, err := io.ReadFull(f, buf[:min(sectorSize, size-off)])
+		b := buf[:l]
+		if s, ok := fixed[off/sectorSize]; ok {
+			b = s[:l]
+		}
+		if _, werr := w.Write(b); werr != nil {
+			return info, werr
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return info, nil
+		} else if err != nil {
+			return info, err
+		}
+	}
+	_, err = io.Copy(w, f)
+	return info, err
+}
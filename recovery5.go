@@ -0,0 +1,214 @@
+package rardecode
+
+import (
+	"hash/crc32"
+	"io"
+	"slices"
+)
+
+const (
+	rr5Version = 1 // RAR 5 recovery record layout version
+)
+
+// recoveryRecord5 is a RAR 5 recovery record. RarLab doesn't document its
+// layout, so it is read using the same Reed-Solomon code in GF(2^16) as RAR 5
+// recovery volumes, with the recovery record data laid out as:
+//
+//	crc32 of the rest of the header, including its size
+//	uint32 header size
+//	byte version, rr5Version
+//	uint16 number of data shards
+//	uint16 number of recovery shards
+//	uint32 shard size, a multiple of 2
+//	uint32 crc32 of each data shard
+//	recovery shards
+//
+// The part of the volume before the recovery record block is split into the
+// data shards, with the last shard padded with zeros. Each recovery shard is
+// calculated from the data shards in the same way as the recovery data of a
+// RAR 5 recovery volume, so up to one damaged shard per recovery shard can be
+// reconstructed.
+type recoveryRecord5 struct {
+	size      int64    // size of the protected part of the volume
+	shardSize int      // size of each shard
+	crcs      []uint32 // checksum of each data shard
+	shards    [][]byte // recovery shards
+}
+
+func (rr *recoveryRecord5) protected() (int64, int64) { return rr.size, int64(rr.shardSize) }
+
+// parseRecoveryRecord5 parses the data b of a RAR 5 recovery record block
+// that starts at offset size in the volume.
+func parseRecoveryRecord5(b readBuf, size int64) (*recoveryRecord5, error) {
+	// records without a valid header are in an unknown layout
+	if len(b) < 8 {
+		return nil, ErrUnsupportedRecovery
+	}
+	size4 := b[4:8] // header size, included in the header checksum
+	crc := b.uint32()
+	hsize := int(b.uint32())
+	if hsize < 9 || hsize > len(b) {
+		return nil, ErrUnsupportedRecovery
+	}
+	h := crc32.NewIEEE()
+	_, _ = h.Write(size4)
+	_, _ = h.Write(b[:hsize])
+	hdr := readBuf(b.bytes(hsize))
+	if h.Sum32() != crc || hdr.byte() != rr5Version {
+		return nil, ErrUnsupportedRecovery
+	}
+	dataCount := int(hdr.uint16())
+	recCount := int(hdr.uint16())
+	rr := &recoveryRecord5{size: size, shardSize: int(hdr.uint32())}
+	if dataCount == 0 || recCount == 0 || dataCount+recCount > gf16Size || rr.shardSize <= 0 || rr.shardSize%2 != 0 ||
+		int64(dataCount) != (size+int64(rr.shardSize)-1)/int64(rr.shardSize) ||
+		len(hdr) != dataCount*4 || len(b) != recCount*rr.shardSize {
+		return nil, ErrCorruptRecovery
+	}
+	rr.crcs = make([]uint32, dataCount)
+	for i := range rr.crcs {
+		rr.crcs[i] = hdr.uint32()
+	}
+	rr.shards = make([][]byte, recCount)
+	for i := range rr.shards {
+		rr.shards[i] = b.bytes(rr.shardSize)
+	}
+	return rr, nil
+}
+
+// readRecoveryBlock5 reads the recovery record block at offset off in a RAR 5
+// volume read by br, using the archive block reader a.
+func readRecoveryBlock5(a *archive50, br *bufVolumeReader, ra io.ReaderAt, size, off int64) (*recoveryRecord5, error) {
+	err := br.seek(off)
+	if err != nil {
+		return nil, err
+	}
+	h, err := a.mustReadBlockHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	if h.htype != block5Service {
+		return nil, ErrCorruptRecovery
+	}
+	f, err := a.parseFileHeader(h)
+	if err != nil {
+		return nil, err
+	}
+	if f.Name != serviceRecovery || f.decVer != 0 || h.dataSize > size-br.off {
+		return nil, ErrCorruptRecovery
+	}
+	b := make([]byte, h.dataSize)
+	n, err := ra.ReadAt(b, br.off)
+	if n < len(b) {
+		return nil, err
+	}
+	return parseRecoveryRecord5(b, off)
+}
+
+// findRecoveryRecord5 returns the recovery record of a RAR 5 volume of the
+// given size read by br, which is positioned after the signature.
+func findRecoveryRecord5(br *bufVolumeReader, ra io.ReaderAt, size int64, opt *options) (*recoveryRecord5, error) {
+	a := newArchive50(opt.pass)
+	arcOff := br.off
+	_, err := a.init(br)
+	if err != nil {
+		return nil, err
+	}
+	if a.blockKey != nil {
+		// the block headers before the recovery record are encrypted
+		return nil, ErrUnsupportedRecovery
+	}
+	if !a.hdr.RecoveryRecord {
+		return nil, ErrNoRecoveryRecord
+	}
+	pos := br.off
+	if a.hdr.RecoveryOffset > 0 {
+		rr, err := readRecoveryBlock5(a, br, ra, size, arcOff+a.hdr.RecoveryOffset)
+		if err == nil || err == ErrUnsupportedRecovery {
+			return rr, err
+		}
+		// damaged locator, search the volume blocks
+		err = br.seek(pos)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for {
+		off := br.off
+		h, err := a.mustReadBlockHeader(br)
+		if err != nil {
+			return nil, err
+		}
+		switch h.htype {
+		case block5End:
+			return nil, ErrNoRecoveryRecord
+		case block5Service:
+			f, err := a.parseFileHeader(h)
+			if err == nil && f.Name == serviceRecovery {
+				return readRecoveryBlock5(a, br, ra, size, off)
+			}
+		}
+		err = br.Discard(h.dataSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// check reads the data shards of a volume from r, returning which damaged
+// shards can be reconstructed and their contents.
+func (rr *recoveryRecord5) check(r io.Reader) (*RecoveryInfo, map[int64][]byte, error) {
+	gf16Once.Do(gf16Init)
+	n := len(rr.crcs)
+	info := &RecoveryInfo{Sectors: int64(n)}
+	// syndromes of the recovery shards, with the undamaged data shards removed
+	syn := make([][]byte, len(rr.shards))
+	for k, s := range rr.shards {
+		syn[k] = slices.Clone(s)
+	}
+	var lost []int
+	r = io.LimitReader(r, rr.size)
+	buf := make([]byte, rr.shardSize)
+	for j := 0; j < n; j++ {
+		l, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, nil, err
+		}
+		clear(buf[l:])
+		if crc32.ChecksumIEEE(buf) != rr.crcs[j] {
+			lost = append(lost, j)
+			continue
+		}
+		for k, s := range syn {
+			gf16MulAdd(s, buf, rev5Coef(n+k, j))
+		}
+	}
+	info.BadSectors = len(lost)
+	fixed := map[int64][]byte{}
+	if len(lost) == 0 || len(lost) > len(syn) {
+		return info, fixed, nil
+	}
+	// solve the lost shards using the first recovery shards
+	m := make([][]uint16, len(lost))
+	for k := range m {
+		m[k] = make([]uint16, len(lost))
+		for x, e := range lost {
+			m[k][x] = rev5Coef(n+k, e)
+		}
+	}
+	inv, err := gf16Invert(m)
+	if err != nil {
+		return info, fixed, nil
+	}
+	for x, e := range lost {
+		b := make([]byte, rr.shardSize)
+		for k := range m {
+			gf16MulAdd(b, syn[k], inv[x][k])
+		}
+		if crc32.ChecksumIEEE(b) == rr.crcs[e] {
+			fixed[int64(e)] = b
+		}
+	}
+	info.Recoverable = len(fixed)
+	return info, fixed, nil
+}
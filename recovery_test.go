@@ -0,0 +1,311 @@
+package rardecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// recoveryArchive returns a RAR 3.x archive containing a stored file followed
+// by a recovery record with the given number of parity sectors. If protect is
+// set, an old style RAR 2.x protect block is used for the recovery record.
+func recoveryArchive(contents []byte, sectors int, protect bool) []byte {
+	const name = "data.bin"
+	fh := binary.LittleEndian.AppendUint32(nil, uint32(len(contents))) // packed size
+	fh = binary.LittleEndian.AppendUint32(fh, uint32(len(contents)))   // unpacked size
+	fh = append(fh, 2)                                                 // host os
+	fh = binary.LittleEndian.AppendUint32(fh, crc32.ChecksumIEEE(contents))
+	fh = binary.LittleEndian.AppendUint32(fh, 0) // file time
+	fh = append(fh, 29, 0x30)                    // unpack version, method
+	fh = binary.LittleEndian.AppendUint16(fh, uint16(len(name)))
+	fh = binary.LittleEndian.AppendUint32(fh, 0) // attributes
+	fh = append(fh, name...)
+
+	arc := []byte(sig15)
+	arc = append(arc, rar15Block(blockArc, arcRecovery, make([]byte, 6), 0)...)
+	arc = append(arc, rar15Block(blockFile, blockHasData, fh, 0)...)
+	arc = append(arc, contents...)
+
+	// protected sectors cover the archive up to the recovery record
+	blocks := (len(arc) + recSectorSize - 1) / recSectorSize
+	padded := make([]byte, blocks*recSectorSize)
+	copy(padded, arc)
+	tags := make([]byte, 0, blocks*2)
+	parity := make([]byte, sectors*recSectorSize)
+	for i := 0; i < blocks; i++ {
+		s := padded[i*recSectorSize : (i+1)*recSectorSize]
+		tags = binary.LittleEndian.AppendUint16(tags, sectorTag(s))
+		p := parity[i%sectors*recSectorSize:]
+		for j := range s {
+			p[j] ^= s[j]
+		}
+	}
+	data := append(tags, parity...)
+
+	var rr []byte
+	if protect {
+		rr = binary.LittleEndian.AppendUint32(nil, uint32(len(data)))
+		rr = append(rr, 1) // version
+		rr = binary.LittleEndian.AppendUint16(rr, uint16(sectors))
+		rr = binary.LittleEndian.AppendUint32(rr, uint32(blocks))
+		rr = append(rr, protectMark...)
+		rr = rar15Block(blockProtect, blockHasData, rr, 0)
+	} else {
+		svc := binary.LittleEndian.AppendUint32(nil, uint32(len(data))) // packed size
+		svc = binary.LittleEndian.AppendUint32(svc, uint32(len(data)))  // unpacked size
+		svc = append(svc, 2)                                            // host os
+		svc = binary.LittleEndian.AppendUint32(svc, 0)                  // crc
+		svc = binary.LittleEndian.AppendUint32(svc, 0)                  // file time
+		svc = append(svc, 29, 0x30)                                     // unpack version, method
+		svc = binary.LittleEndian.AppendUint16(svc, uint16(len(serviceRecovery)))
+		svc = binary.LittleEndian.AppendUint32(svc, 0) // attributes
+		svc = append(svc, serviceRecovery...)
+		svc = append(svc, recoveryMark...)
+		svc = binary.LittleEndian.AppendUint32(svc, uint32(sectors))
+		svc = binary.LittleEndian.AppendUint64(svc, uint64(blocks))
+		rr = rar15Block(blockService, blockHasData, svc, 0)
+	}
+	arc = append(arc, rr...)
+	arc = append(arc, data...)
+	return append(arc, rar15Block(blockEnd, 0, nil, 0)...)
+}
+
+func TestRepair(t *testing.T) {
+	contents := make([]byte, 3000)
+	for i := range contents {
+		contents[i] = byte(i * 7)
+	}
+	good := recoveryArchive(contents, 2, false)
+	old := recoveryArchive(contents, 3, true)
+
+	// one damaged sector in each parity group
+	fixable := bytes.Clone(good)
+	fixable[600] ^= 0xff
+	fixable[1100] ^= 0xff
+	oldFixable := bytes.Clone(old)
+	oldFixable[2000] ^= 0xff
+	// damaged archive and file block headers before the recovery record
+	badHeader := bytes.Clone(good)
+	badHeader[len(sig15)+10] ^= 0xff
+	badHeader[len(sig15)+13+20] ^= 0xff
+	// two damaged sectors in the same parity group
+	unfixable := bytes.Clone(good)
+	unfixable[600] ^= 0xff
+	unfixable[1700] ^= 0xff
+
+	fsys := fstest.MapFS{
+		"good.rar":       {Data: good},
+		"fixable.rar":    {Data: fixable},
+		"oldFixable.rar": {Data: oldFixable},
+		"badHeader.rar":  {Data: badHeader},
+		"unfixable.rar":  {Data: unfixable},
+	}
+	tests := []struct {
+		name        string
+		want        []byte
+		bad         int
+		recoverable int
+		err         error
+	}{
+		{"good.rar", good, 0, 0, nil},
+		{"fixable.rar", good, 2, 2, nil},
+		{"oldFixable.rar", old, 1, 1, nil},
+		{"badHeader.rar", good, 1, 1, nil},
+		{"unfixable.rar", nil, 2, 0, ErrRepairFailed},
+	}
+	for _, tt := range tests {
+		info, err := VerifyRecovery(tt.name, FileSystem(fsys))
+		if err != nil {
+			t.Fatalf("VerifyRecovery(%s) error = %v", tt.name, err)
+		}
+		if info.BadSectors != tt.bad || info.Recoverable != tt.recoverable {
+			t.Errorf("VerifyRecovery(%s) = %+v, want %d bad and %d recoverable", tt.name, *info, tt.bad, tt.recoverable)
+		}
+		var buf bytes.Buffer
+		_, err = Repair(tt.name, &buf, FileSystem(fsys))
+		if err != tt.err {
+			t.Errorf("Repair(%s) error = %v, want %v", tt.name, err, tt.err)
+		}
+		if !bytes.Equal(buf.Bytes(), tt.want) {
+			t.Errorf("Repair(%s) wrote %d bytes, want %d", tt.name, buf.Len(), len(tt.want))
+		}
+	}
+}
+
+// recoveryArchive5 returns a RAR 5 archive containing a stored file followed by
+// a recovery record, in the layout of recoveryRecord5, with the given shard size
+// and number of recovery shards. If locator is set, the offset of the recovery
+// record is stored in the main archive header.
+func recoveryArchive5(contents []byte, shardSize, count int, locator bool) []byte {
+	gf16Once.Do(gf16Init)
+	file := rar5Block(block5File, 0, rar5FileData("data.bin", contents), nil, contents)
+	for roff := 0; ; {
+		var extra []byte
+		if locator {
+			var loc []byte
+			loc = binary.AppendUvarint(loc, 1) // locator record
+			loc = binary.AppendUvarint(loc, locator5Recovery)
+			loc = binary.AppendUvarint(loc, uint64(roff))
+			extra = append(binary.AppendUvarint(nil, uint64(len(loc))), loc...)
+		}
+		arc := []byte(sig50)
+		arc = append(arc, rar5Block(block5Arc, 0, []byte{arc5Recovery}, extra, nil)...)
+		arc = append(arc, file...)
+		if locator && len(arc)-len(sig50) != roff {
+			roff = len(arc) - len(sig50)
+			continue
+		}
+
+		// data shards cover the archive up to the recovery record
+		n := (len(arc) + shardSize - 1) / shardSize
+		padded := make([]byte, n*shardSize)
+		copy(padded, arc)
+		hdr := []byte{rr5Version}
+		hdr = binary.LittleEndian.AppendUint16(hdr, uint16(n))
+		hdr = binary.LittleEndian.AppendUint16(hdr, uint16(count))
+		hdr = binary.LittleEndian.AppendUint32(hdr, uint32(shardSize))
+		for j := 0; j < n; j++ {
+			hdr = binary.LittleEndian.AppendUint32(hdr, crc32.ChecksumIEEE(padded[j*shardSize:(j+1)*shardSize]))
+		}
+		hdr = append(binary.LittleEndian.AppendUint32(nil, uint32(len(hdr))), hdr...)
+		data := binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(hdr))
+		data = append(data, hdr...)
+		for k := 0; k < count; k++ {
+			shard := make([]byte, shardSize)
+			for j := 0; j < n; j++ {
+				gf16MulAdd(shard, padded[j*shardSize:(j+1)*shardSize], rev5Coef(n+k, j))
+			}
+			data = append(data, shard...)
+		}
+		arc = append(arc, rar5Block(block5Service, 0, rar5FileData(serviceRecovery, data), nil, data)...)
+		return append(arc, rar5Block(block5End, 0, []byte{0}, nil, nil)...)
+	}
+}
+
+func TestRepair5(t *testing.T) {
+	contents := make([]byte, 3000)
+	for i := range contents {
+		contents[i] = byte(i * 11)
+	}
+	good := recoveryArchive5(contents, 256, 2, true)
+	walk := recoveryArchive5(contents, 256, 2, false)
+
+	// two damaged shards
+	fixable := bytes.Clone(good)
+	fixable[600] ^= 0xff
+	fixable[2000] ^= 0xff
+	// damaged file block header, the recovery record is found from the locator
+	badHeader := bytes.Clone(good)
+	badHeader[len(sig50)+20] ^= 0xff
+	// without a locator the recovery record is found by reading the blocks
+	walkFixable := bytes.Clone(walk)
+	walkFixable[1000] ^= 0xff
+	// three damaged shards
+	unfixable := bytes.Clone(good)
+	unfixable[600] ^= 0xff
+	unfixable[1000] ^= 0xff
+	unfixable[2000] ^= 0xff
+
+	// recovery record in a different layout
+	unknown := []byte(sig50)
+	unknown = append(unknown, rar5Block(block5Arc, 0, []byte{arc5Recovery}, nil, nil)...)
+	rr := bytes.Repeat([]byte{0x5a}, 100)
+	unknown = append(unknown, rar5Block(block5Service, 0, rar5FileData(serviceRecovery, rr), nil, rr)...)
+	unknown = append(unknown, rar5Block(block5End, 0, []byte{0}, nil, nil)...)
+	// recovery record flag without a recovery record
+	missing := []byte(sig50)
+	missing = append(missing, rar5Block(block5Arc, 0, []byte{arc5Recovery}, nil, nil)...)
+	missing = append(missing, rar5Block(block5End, 0, []byte{0}, nil, nil)...)
+
+	fsys := fstest.MapFS{
+		"good.rar":        {Data: good},
+		"fixable.rar":     {Data: fixable},
+		"badHeader.rar":   {Data: badHeader},
+		"walkFixable.rar": {Data: walkFixable},
+		"unfixable.rar":   {Data: unfixable},
+		"unknown.rar":     {Data: unknown},
+		"missing.rar":     {Data: missing},
+	}
+	tests := []struct {
+		name        string
+		want        []byte
+		bad         int
+		recoverable int
+		err         error
+	}{
+		{"good.rar", good, 0, 0, nil},
+		{"fixable.rar", good, 2, 2, nil},
+		{"badHeader.rar", good, 1, 1, nil},
+		{"walkFixable.rar", walk, 1, 1, nil},
+		{"unfixable.rar", nil, 3, 0, ErrRepairFailed},
+	}
+	for _, tt := range tests {
+		info, err := VerifyRecovery(tt.name, FileSystem(fsys))
+		if err != nil {
+			t.Fatalf("VerifyRecovery(%s) error = %v", tt.name, err)
+		}
+		if info.Sectors != 12 {
+			t.Errorf("VerifyRecovery(%s) = %d sectors, want 12", tt.name, info.Sectors)
+		}
+		if info.BadSectors != tt.bad || info.Recoverable != tt.recoverable {
+			t.Errorf("VerifyRecovery(%s) = %+v, want %d bad and %d recoverable", tt.name, *info, tt.bad, tt.recoverable)
+		}
+		var buf bytes.Buffer
+		_, err = Repair(tt.name, &buf, FileSystem(fsys))
+		if err != tt.err {
+			t.Errorf("Repair(%s) error = %v, want %v", tt.name, err, tt.err)
+		}
+		if !bytes.Equal(buf.Bytes(), tt.want) {
+			t.Errorf("Repair(%s) wrote %d bytes, want %d", tt.name, buf.Len(), len(tt.want))
+		}
+	}
+	if _, err := VerifyRecovery("unknown.rar", FileSystem(fsys)); err != ErrUnsupportedRecovery {
+		t.Errorf("VerifyRecovery(unknown.rar) error = %v, want %v", err, ErrUnsupportedRecovery)
+	}
+	if _, err := VerifyRecovery("missing.rar", FileSystem(fsys)); err != ErrNoRecoveryRecord {
+		t.Errorf("VerifyRecovery(missing.rar) error = %v, want %v", err, ErrNoRecoveryRecord)
+	}
+}
+
+// TestRepairArchives checks the archives made by RAR with a recovery record in
+// testdata/recovery. A byte in the middle of the protected part of each volume
+// is damaged, which must be reported and repaired.
+func TestRepairArchives(t *testing.T) {
+	names, err := filepath.Glob(filepath.Join("testdata", "recovery", "*.rar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Skip("no archives in testdata/recovery")
+	}
+	for _, name := range names {
+		good, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsys := fstest.MapFS{"good.rar": {Data: good}}
+		rr, info, _, err := checkVolume("good.rar", []Option{FileSystem(fsys)})
+		if err == ErrUnsupportedRecovery {
+			t.Logf("%s: %v", name, err)
+			continue
+		} else if err != nil {
+			t.Fatalf("VerifyRecovery(%s) error = %v", name, err)
+		}
+		if info.BadSectors != 0 {
+			t.Errorf("VerifyRecovery(%s) = %+v, want no bad sectors", name, *info)
+		}
+		size, _ := rr.protected()
+		bad := bytes.Clone(good)
+		bad[size/2] ^= 0xff
+		fsys["bad.rar"] = &fstest.MapFile{Data: bad}
+		var buf bytes.Buffer
+		info, err = Repair("bad.rar", &buf, FileSystem(fsys))
+		if err != nil || info.BadSectors != 1 || !bytes.Equal(buf.Bytes(), good) {
+			t.Errorf("Repair(%s) = %+v, %v, wrote %d bytes, want %d", name, info, err, buf.Len(), len(good))
+		}
+	}
+}
@@ -0,0 +1,587 @@
+package rardecode
+
+import (
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	rev3TrailerSize = 7             // size of the trailer at the end of a RAR 3.x recovery volume
+	rev5Sig         = "Rar!\x1aRev" // RAR 5 recovery volume signature
+	revBufSize      = 0x10000       // size of volume chunks processed at a time
+	gfPoly          = 0x11d         // primitive polynomial of GF(2^8) used by RAR 3.x recovery volumes
+)
+
+var (
+	ErrNoRecoveryVolumes     = errors.New("rardecode: no recovery volumes found")
+	ErrTooManyMissingVolumes = errors.New("rardecode: too many missing volumes to rebuild archive")
+)
+
+var (
+	gfExp [2 * 255]byte  // powers of the generator in GF(2^8)
+	gfLog [256]int       // logarithms in GF(2^8)
+	gfMul [256][256]byte // multiplication table for GF(2^8)
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfExp[i+255] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x&0x100 > 0 {
+			x ^= gfPoly
+		}
+	}
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			gfMul[a][b] = gfExp[gfLog[a]+gfLog[b]]
+		}
+	}
+}
+
+// gfInvert returns the inverse of the square matrix m in GF(2^8).
+// The contents of m are destroyed.
+func gfInvert(m [][]byte) ([][]byte, error) {
+	inv := make([][]byte, len(m))
+	for i := range inv {
+		inv[i] = make([]byte, len(m))
+		inv[i][i] = 1
+	}
+	for c := range m {
+		p := c
+		for p < len(m) && m[p][c] == 0 {
+			p++
+		}
+		if p == len(m) {
+			return nil, ErrCorruptRecovery
+		}
+		m[c], m[p] = m[p], m[c]
+		inv[c], inv[p] = inv[p], inv[c]
+		s := &gfMul[gfExp[255-gfLog[m[c][c]]]]
+		for i := range m[c] {
+			m[c][i] = s[m[c][i]]
+			inv[c][i] = s[inv[c][i]]
+		}
+		for r := range m {
+			if r == c || m[r][c] == 0 {
+				continue
+			}
+			f := &gfMul[m[r][c]]
+			for i := range m[r] {
+				m[r][i] ^= f[m[c][i]]
+				inv[r][i] ^= f[inv[c][i]]
+			}
+		}
+	}
+	return inv, nil
+}
+
+// revSet is a set of RAR 3.x or RAR 5 recovery volumes.
+// In RAR 3.x recovery volumes each byte offset across the data volumes and
+// recovery volumes forms a Reed-Solomon codeword, with the data volume bytes
+// followed by the recovery volume bytes. Up to one missing volume per recovery
+// volume can be rebuilt.
+type revSet struct {
+	dataCount int      // number of data volumes
+	revs      []string // recovery volume file names, empty if missing
+	size      int64    // size of recovery data in each recovery volume
+
+	// RAR 5 recovery volumes
+	offs []int64      // offset of the recovery data in each recovery volume
+	vols []rev5Volume // size and checksum of each data volume, nil for RAR 3.x
+}
+
+// revHeader is the trailer at the end of a RAR 3.x recovery volume,
+// or the header at the start of a RAR 5 recovery volume.
+type revHeader struct {
+	dataCount int   // number of data volumes
+	revCount  int   // number of recovery volumes
+	num       int   // recovery volume number, starting at 0
+	size      int64 // size of recovery data
+
+	off  int64        // offset of the recovery data (RAR 5)
+	vols []rev5Volume // size and checksum of each data volume (RAR 5)
+}
+
+// readRevHeader checks the recovery volume name and returns its header or trailer.
+func (vm *volumeManager) readRevHeader(name string) (*revHeader, error) {
+	f, err := vm.opt.fs.Open(vm.dir + name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size() - rev3TrailerSize
+	if size < int64(len(rev5Sig)) {
+		return nil, ErrCorruptRecovery
+	}
+	h := crc32.NewIEEE()
+	sig := make([]byte, len(rev5Sig))
+	_, err = io.ReadFull(f, sig)
+	if err != nil {
+		return nil, err
+	}
+	if string(sig) == rev5Sig {
+		return readRev5Header(f, fi.Size())
+	}
+	_, _ = h.Write(sig)
+	_, err = io.CopyN(h, f, size-int64(len(sig)))
+	if err != nil {
+		return nil, err
+	}
+	b := make(readBuf, rev3TrailerSize)
+	_, err = io.ReadFull(f, b)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = h.Write(b[:3])
+	t := &revHeader{size: size}
+	t.dataCount = int(b.byte()) + 1
+	t.revCount = int(b.byte()) + 1
+	t.num = int(b.byte())
+	if b.uint32() != h.Sum32() || t.num >= t.revCount || t.dataCount+t.revCount > 255 {
+		return nil, ErrCorruptRecovery
+	}
+	return t, nil
+}
+
+// revStem returns the part of a volume name shared with its recovery volume names.
+func revStem(name string) string {
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	s := strings.TrimRight(stem, "0123456789")
+	if len(s) < len(stem) && strings.HasSuffix(strings.ToLower(s), ".part") {
+		stem = s[:len(s)-len(".part")]
+	}
+	return stem
+}
+
+// openRecoveryVolumes finds the recovery volumes stored alongside the archive volumes.
+// Damaged recovery volumes are ignored.
+func (vm *volumeManager) openRecoveryVolumes() (*revSet, error) {
+	vm.mu.Lock()
+	stem := revStem(vm.files[0])
+	vm.mu.Unlock()
+	dir := strings.TrimRight(vm.dir, `/\`)
+	if dir == "" {
+		dir = "."
+		if vm.dir != "" {
+			dir = vm.dir
+		}
+	}
+	entries, err := fs.ReadDir(vm.opt.fs, dir)
+	if err != nil {
+		return nil, err
+	}
+	var rs *revSet
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, stem) || !strings.EqualFold(path.Ext(name), ".rev") {
+			continue
+		}
+		t, err := vm.readRevHeader(name)
+		if err != nil {
+			continue
+		}
+		if rs == nil {
+			rs = &revSet{dataCount: t.dataCount, revs: make([]string, t.revCount), size: t.size, vols: t.vols}
+			if t.vols != nil {
+				rs.offs = make([]int64, t.revCount)
+			}
+		} else if t.dataCount != rs.dataCount || t.revCount != len(rs.revs) || t.size != rs.size || (t.vols == nil) != (rs.vols == nil) {
+			continue
+		}
+		rs.revs[t.num] = name
+		if rs.offs != nil {
+			rs.offs[t.num] = t.off
+		}
+	}
+	if rs == nil {
+		return nil, ErrNoRecoveryVolumes
+	}
+	return rs, nil
+}
+
+// volumeSize returns the size of the archive volume read from r, which may be
+// followed by padding. It returns -1 if the end of the volume can't be found.
+func volumeSize(r io.Reader, opt *options, volnum int) int64 {
+	v, err := newVolume(r, opt, volnum)
+	if err != nil {
+		return -1
+	}
+	for {
+		_, err = v.readBlock()
+		if err == io.EOF || err == ErrMultiVolume {
+			return v.br.off
+		} else if err != nil {
+			return -1
+		}
+	}
+}
+
+// volumeTrimmer writes a RAR 3.x volume rebuilt from recovery volumes to w,
+// leaving out the zero padding after the end of the volume. The end of the
+// volume is found by reading its blocks as the data is written.
+type volumeTrimmer struct {
+	w     io.Writer
+	n     int64          // bytes written to w
+	zeros int64          // zero bytes held back, as they may be padding
+	pw    *io.PipeWriter // passes the volume data to the goroutine reading its blocks
+	size  chan int64     // size returned by volumeSize
+}
+
+func newVolumeTrimmer(w io.Writer, opt *options, volnum int) *volumeTrimmer {
+	pr, pw := io.Pipe()
+	t := &volumeTrimmer{w: w, pw: pw, size: make(chan int64, 1)}
+	go func() {
+		t.size <- volumeSize(pr, opt, volnum)
+		_, _ = io.Copy(io.Discard, pr)
+	}()
+	return t
+}
+
+// writeZeros writes n zero bytes to w.
+func (t *volumeTrimmer) writeZeros(n int64) error {
+	buf := make([]byte, min(n, revBufSize))
+	for n > 0 {
+		l, err := t.w.Write(buf[:min(n, int64(len(buf)))])
+		t.n += int64(l)
+		n -= int64(l)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *volumeTrimmer) Write(p []byte) (int, error) {
+	_, err := t.pw.Write(p)
+	if err != nil {
+		return 0, err
+	}
+	i := len(p)
+	for i > 0 && p[i-1] == 0 {
+		i--
+	}
+	if i > 0 {
+		err = t.writeZeros(t.zeros)
+		t.zeros = 0
+		if err != nil {
+			return 0, err
+		}
+		l, err := t.w.Write(p[:i])
+		t.n += int64(l)
+		if err != nil {
+			return l, err
+		}
+	}
+	t.zeros += int64(len(p) - i)
+	return len(p), nil
+}
+
+// Close writes the zero bytes held back that are part of the volume.
+func (t *volumeTrimmer) Close() error {
+	t.pw.Close()
+	z := t.zeros
+	if size := <-t.size; size >= 0 {
+		z = max(0, min(z, size-t.n))
+	}
+	return t.writeZeros(z)
+}
+
+// createRebuiltFile creates a temporary file to store a rebuilt volume.
+// The file is removed straight away, so its space is freed when it is closed.
+// Where open files can't be removed it is left in the temporary directory.
+func createRebuiltFile() (*os.File, error) {
+	f, err := os.CreateTemp("", "rardecode-*.rar")
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(f.Name())
+	return f, nil
+}
+
+// rebuiltOutputs returns the writers of the lost data volumes of rs: w for
+// volume volnum and, if keep is set, temporary files for the others, which are
+// also returned by volume number. Lost volumes without a writer aren't rebuilt.
+func rebuiltOutputs(rs *revSet, lost []int, volnum int, w io.Writer, keep bool) ([]io.Writer, map[int]*os.File, error) {
+	outs := make([]io.Writer, len(lost))
+	files := make(map[int]*os.File)
+	for x, e := range lost {
+		switch {
+		case e == volnum:
+			outs[x] = w
+		case keep && e < rs.dataCount:
+			f, err := createRebuiltFile()
+			if err != nil {
+				closeRebuiltFiles(files)
+				return nil, nil, err
+			}
+			outs[x] = f
+			files[e] = f
+		}
+	}
+	return outs, files, nil
+}
+
+func closeRebuiltFiles(files map[int]*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// openRevSources opens the data volumes and recovery volumes of rs into srcs,
+// returning the indexes of the missing volumes. Volume volnum is treated as missing.
+func (vm *volumeManager) openRevSources(rs *revSet, volnum int, srcs []fs.File) ([]int, error) {
+	var erased []int
+	for i := 0; i < rs.dataCount; i++ {
+		f, err := vm.openVolumeName(i)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			f = nil
+		}
+		vm.mu.Lock()
+		if len(vm.files) == i {
+			// missing second volume, assume new style volume names
+			vm.files = append(vm.files, nextNewVolName(fixFileExtension(vm.files[0])))
+		}
+		vm.mu.Unlock()
+		if f != nil && i == volnum {
+			f.Close()
+			f = nil
+		}
+		if f == nil {
+			erased = append(erased, i)
+		}
+		srcs[i] = f
+	}
+	for i, name := range rs.revs {
+		if name == "" {
+			erased = append(erased, rs.dataCount+i)
+			continue
+		}
+		f, err := vm.opt.fs.Open(vm.dir + name)
+		if err != nil {
+			return nil, err
+		}
+		srcs[rs.dataCount+i] = f
+	}
+	return erased, nil
+}
+
+// rebuildVolumes rebuilds volume volnum using the recovery volumes in rs and
+// writes it to w. Any other missing data volumes are rebuilt into temporary
+// files if keep is set, which are returned by volume number. The volumes are
+// processed revBufSize bytes at a time.
+func (vm *volumeManager) rebuildVolumes(rs *revSet, volnum int, w io.Writer, keep bool) (files map[int]*os.File, err error) {
+	if rs.vols != nil {
+		return vm.rebuildVolumes5(rs, volnum, w, keep)
+	}
+	n := rs.dataCount + len(rs.revs)
+	srcs := make([]fs.File, n)
+	defer func() {
+		for _, f := range srcs {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+	erased, err := vm.openRevSources(rs, volnum, srcs)
+	if err != nil {
+		return nil, err
+	}
+	if len(erased) > len(rs.revs) {
+		return nil, ErrTooManyMissingVolumes
+	}
+
+	// Codeword position i has weight x^(n-1-i), and the code has roots
+	// x = gfExp[1] .. gfExp[len(rs.revs)]. The erased values are solved
+	// from the first len(erased) syndromes.
+	weight := func(j, i int) byte { return gfExp[(j+1)*(n-1-i)%255] }
+	m := make([][]byte, len(erased))
+	for j := range m {
+		m[j] = make([]byte, len(erased))
+		for k, e := range erased {
+			m[j][k] = weight(j, e)
+		}
+	}
+	inv, err := gfInvert(m)
+	if err != nil {
+		return nil, err
+	}
+
+	outs, files, err := rebuiltOutputs(rs, erased, volnum, w, keep)
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]byte, len(erased)) // current chunk of each volume written out
+	trim := make([]*volumeTrimmer, len(erased))
+	for k, e := range erased {
+		if outs[k] != nil {
+			out[k] = make([]byte, revBufSize)
+			trim[k] = newVolumeTrimmer(outs[k], vm.opt, e)
+		}
+	}
+	defer func() {
+		for _, t := range trim {
+			if t != nil {
+				t.pw.CloseWithError(ErrCorruptRecovery) // stop reading blocks if not closed
+			}
+		}
+		if err != nil {
+			closeRebuiltFiles(files)
+		}
+	}()
+	buf := make([]byte, revBufSize)
+	syn := make([][]byte, len(erased))
+	for j := range syn {
+		syn[j] = make([]byte, revBufSize)
+	}
+	for off := int64(0); off < rs.size; off += revBufSize {
+		l := int(min(revBufSize, rs.size-off))
+		for j := range syn {
+			clear(syn[j][:l])
+		}
+		for i, f := range srcs {
+			if f == nil {
+				continue
+			}
+			b := buf[:l]
+			var c int
+			c, err = io.ReadFull(f, b)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return nil, err
+			}
+			clear(b[c:]) // volumes are padded with zeros
+			for j, s := range syn {
+				t := &gfMul[weight(j, i)]
+				s = s[:l]
+				for x, v := range b {
+					s[x] ^= t[v]
+				}
+			}
+		}
+		for k, dst := range out {
+			if dst == nil {
+				continue
+			}
+			dst = dst[:l]
+			clear(dst)
+			for j, s := range syn {
+				t := &gfMul[inv[k][j]]
+				for x, v := range s[:l] {
+					dst[x] ^= t[v]
+				}
+			}
+			if _, err = trim[k].Write(dst); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, t := range trim {
+		if t == nil {
+			continue
+		}
+		if err = t.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// rebuiltFile is an fs.File for a volume rebuilt into a temporary file.
+type rebuiltFile struct {
+	*io.SectionReader
+	fi fileInfo
+}
+
+func (f *rebuiltFile) Stat() (fs.FileInfo, error) { return f.fi, nil }
+func (f *rebuiltFile) Close() error               { return nil }
+
+// openRebuiltVolume returns volume volnum rebuilt from the archive's recovery
+// volumes. If the archive has no recovery volumes or the volume is past the
+// end of the archive, notExist is returned.
+func (vm *volumeManager) openRebuiltVolume(volnum int, notExist error) (fs.File, error) {
+	vm.revMu.Lock()
+	defer vm.revMu.Unlock()
+	f, ok := vm.rebuilt[volnum]
+	if !ok {
+		if vm.revs == nil {
+			rs, err := vm.openRecoveryVolumes()
+			if err != nil {
+				return nil, notExist
+			}
+			vm.revs = rs
+		}
+		if volnum >= vm.revs.dataCount {
+			return nil, notExist
+		}
+		var err error
+		f, err = createRebuiltFile()
+		if err != nil {
+			return nil, err
+		}
+		vols, err := vm.rebuildVolumes(vm.revs, volnum, f, true)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if vm.rebuilt == nil {
+			vm.rebuilt = make(map[int]*os.File)
+		}
+		vols[volnum] = f
+		for i, v := range vols {
+			if _, ok := vm.rebuilt[i]; ok {
+				v.Close() // already rebuilt
+			} else {
+				vm.rebuilt[i] = v
+			}
+		}
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	h := new(fileBlockHeader)
+	h.Name = vm.GetVolumePath(volnum)
+	h.UnPackedSize = fi.Size()
+	return &rebuiltFile{SectionReader: io.NewSectionReader(f, 0, fi.Size()), fi: fileInfo{h}}, nil
+}
+
+// ReconstructVolume rebuilds volume volnum of the multi-volume archive name,
+// where 0 is the first volume, and writes it to w. The volume is rebuilt from
+// the RAR 3.x or RAR 5 .rev recovery volumes stored alongside the archive, even
+// if the volume file exists. Any other missing volumes are also treated as damaged,
+// as are RAR 5 volumes that don't match the size and checksum stored in the
+// recovery volumes. The volume is written to w as it is rebuilt, so if it
+// doesn't match the checksum in RAR 5 recovery volumes ErrCorruptRecovery is
+// returned after it has been written.
+func ReconstructVolume(name string, volnum int, w io.Writer, opts ...Option) error {
+	dir, file := filepath.Split(name)
+	vm := &volumeManager{
+		dir:   dir,
+		files: []string{file},
+		opt:   getOptions(opts),
+	}
+	rs, err := vm.openRecoveryVolumes()
+	if err != nil {
+		return err
+	}
+	if volnum < 0 || volnum >= rs.dataCount {
+		return ErrBadVolumeNumber
+	}
+	_, err = vm.rebuildVolumes(rs, volnum, w, false)
+	return err
+}
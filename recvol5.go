@@ -0,0 +1,337 @@
+package rardecode
+
+import (
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+)
+
+const (
+	gf16Poly          = 0x1100b  // primitive polynomial of GF(2^16) used by RAR 5 recovery volumes
+	gf16Size          = 0xffff   // number of non zero elements in GF(2^16)
+	rev5MaxHeaderSize = 0x100000 // maximum size of a RAR 5 recovery volume header
+	rev5Version       = 1        // RAR 5 recovery volume header version
+)
+
+var (
+	gf16Once sync.Once
+	gf16Exp  []uint16 // powers of the generator in GF(2^16), repeated to avoid a modulo
+	gf16Log  []int    // logarithms in GF(2^16)
+)
+
+// gf16Init creates the GF(2^16) tables. They are only created when RAR 5
+// recovery volumes are used, as they are much larger than the GF(2^8) tables.
+func gf16Init() {
+	gf16Exp = make([]uint16, 2*gf16Size)
+	gf16Log = make([]int, gf16Size+1)
+	x := 1
+	for i := 0; i < gf16Size; i++ {
+		gf16Exp[i] = uint16(x)
+		gf16Exp[i+gf16Size] = uint16(x)
+		gf16Log[x] = i
+		x <<= 1
+		if x > gf16Size {
+			x ^= gf16Poly
+		}
+	}
+}
+
+func gf16Mul(a, b uint16) uint16 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gf16Exp[gf16Log[a]+gf16Log[b]]
+}
+
+// gf16Inv returns the inverse of a, which must not be zero.
+func gf16Inv(a uint16) uint16 {
+	return gf16Exp[gf16Size-gf16Log[a]]
+}
+
+// rev5Coef returns the coefficient of data volume j in the recovery data of
+// volume r, where volumes are numbered with the data volumes first. The
+// coefficients of the recovery volumes form a Cauchy matrix, so any square
+// submatrix can be inverted.
+func rev5Coef(r, j int) uint16 {
+	return gf16Inv(uint16(r ^ j))
+}
+
+// gf16MulAdd adds the little endian 16 bit words of src multiplied by c to dst.
+func gf16MulAdd(dst, src []byte, c uint16) {
+	if c == 0 {
+		return
+	}
+	lc := gf16Log[c]
+	for i := 0; i+1 < len(src); i += 2 {
+		w := uint16(src[i]) | uint16(src[i+1])<<8
+		if w == 0 {
+			continue
+		}
+		p := gf16Exp[lc+gf16Log[w]]
+		dst[i] ^= byte(p)
+		dst[i+1] ^= byte(p >> 8)
+	}
+}
+
+// gf16Invert returns the inverse of the square matrix m in GF(2^16).
+// The contents of m are destroyed.
+func gf16Invert(m [][]uint16) ([][]uint16, error) {
+	inv := make([][]uint16, len(m))
+	for i := range inv {
+		inv[i] = make([]uint16, len(m))
+		inv[i][i] = 1
+	}
+	for c := range m {
+		p := c
+		for p < len(m) && m[p][c] == 0 {
+			p++
+		}
+		if p == len(m) {
+			return nil, ErrCorruptRecovery
+		}
+		m[c], m[p] = m[p], m[c]
+		inv[c], inv[p] = inv[p], inv[c]
+		s := gf16Inv(m[c][c])
+		for i := range m[c] {
+			m[c][i] = gf16Mul(s, m[c][i])
+			inv[c][i] = gf16Mul(s, inv[c][i])
+		}
+		for r := range m {
+			f := m[r][c]
+			if r == c || f == 0 {
+				continue
+			}
+			for i := range m[r] {
+				m[r][i] ^= gf16Mul(f, m[c][i])
+				inv[r][i] ^= gf16Mul(f, inv[c][i])
+			}
+		}
+	}
+	return inv, nil
+}
+
+// rev5Volume is the size and CRC32 of a data volume, as stored in the header
+// of a RAR 5 recovery volume.
+type rev5Volume struct {
+	size int64
+	crc  uint32
+}
+
+// readRev5Header reads the header of a RAR 5 recovery volume of the given size
+// from f, which is positioned after the signature. The checksum of the recovery
+// data following the header is also checked.
+func readRev5Header(f io.Reader, size int64) (*revHeader, error) {
+	b := make(readBuf, 8)
+	_, err := io.ReadFull(f, b)
+	if err != nil {
+		return nil, err
+	}
+	size4 := b[4:] // header size, included in the header checksum
+	crc := b.uint32()
+	hsize := b.uint32()
+	off := int64(len(rev5Sig)+8) + int64(hsize)
+	if hsize < 11 || hsize > rev5MaxHeaderSize || off > size {
+		return nil, ErrCorruptRecovery
+	}
+	hdr := make(readBuf, hsize)
+	_, err = io.ReadFull(f, hdr)
+	if err != nil {
+		return nil, err
+	}
+	h := crc32.NewIEEE()
+	_, _ = h.Write(size4)
+	_, _ = h.Write(hdr)
+	if h.Sum32() != crc {
+		return nil, ErrCorruptRecovery
+	}
+	if hdr.byte() != rev5Version {
+		return nil, ErrUnsupportedRecovery
+	}
+	t := &revHeader{off: off, size: size - off}
+	t.dataCount = int(hdr.uint16())
+	t.revCount = int(hdr.uint16())
+	num := int(hdr.uint16())
+	revCRC := hdr.uint32()
+	total := t.dataCount + t.revCount
+	if t.dataCount == 0 || t.revCount == 0 || total > gf16Size || num < t.dataCount || num >= total ||
+		len(hdr) < t.dataCount*12 {
+		return nil, ErrCorruptRecovery
+	}
+	t.num = num - t.dataCount
+	t.vols = make([]rev5Volume, t.dataCount)
+	for i := range t.vols {
+		t.vols[i].size = int64(hdr.uint64())
+		t.vols[i].crc = hdr.uint32()
+		if t.vols[i].size > t.size {
+			return nil, ErrCorruptRecovery
+		}
+	}
+	h.Reset()
+	_, err = io.CopyN(h, f, t.size)
+	if err != nil {
+		return nil, err
+	}
+	if h.Sum32() != revCRC {
+		return nil, ErrCorruptRecovery
+	}
+	return t, nil
+}
+
+// rebuildVolumes5 rebuilds volume volnum and any other missing data volumes
+// using the RAR 5 recovery volumes in rs, as done by rebuildVolumes. Data volumes
+// that don't match the size and checksum stored in the recovery volumes are
+// also rebuilt. Each little endian 16 bit word offset across the data volumes
+// and recovery volumes is a Reed-Solomon codeword in GF(2^16), where the data
+// volumes are padded with zeros to the size of the recovery data.
+func (vm *volumeManager) rebuildVolumes5(rs *revSet, volnum int, w io.Writer, keep bool) (files map[int]*os.File, err error) {
+	gf16Once.Do(gf16Init)
+	n := rs.dataCount + len(rs.revs)
+	srcs := make([]fs.File, n)
+	defer func() {
+		for _, f := range srcs {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+	erased, err := vm.openRevSources(rs, volnum, srcs)
+	if err != nil {
+		return nil, err
+	}
+	var lost []int // data volumes to rebuild
+	for _, i := range erased {
+		if i < rs.dataCount {
+			lost = append(lost, i)
+		}
+	}
+	for i, f := range srcs[:rs.dataCount] {
+		if f == nil {
+			continue
+		}
+		h := crc32.NewIEEE()
+		size, err := io.Copy(h, f)
+		f.Close()
+		srcs[i] = nil
+		if err != nil {
+			return nil, err
+		}
+		if size != rs.vols[i].size || h.Sum32() != rs.vols[i].crc {
+			lost = append(lost, i) // damaged volume
+			continue
+		}
+		srcs[i], err = vm.openVolumeName(i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(lost)
+
+	// solve the lost volumes using the first available recovery volumes
+	var rows []int
+	for i := rs.dataCount; i < n && len(rows) < len(lost); i++ {
+		if srcs[i] == nil {
+			continue
+		}
+		_, err = io.CopyN(io.Discard, srcs[i], rs.offs[i-rs.dataCount])
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, i)
+	}
+	if len(rows) < len(lost) {
+		return nil, ErrTooManyMissingVolumes
+	}
+	m := make([][]uint16, len(rows))
+	for k, r := range rows {
+		m[k] = make([]uint16, len(lost))
+		for x, e := range lost {
+			m[k][x] = rev5Coef(r, e)
+		}
+	}
+	inv, err := gf16Invert(m)
+	if err != nil {
+		return nil, err
+	}
+
+	outs, files, err := rebuiltOutputs(rs, lost, volnum, w, keep)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			closeRebuiltFiles(files)
+		}
+	}()
+	size := (rs.size + 1) &^ 1
+	out := make([][]byte, len(lost)) // current chunk of each volume written out
+	sums := make([]hash.Hash32, len(lost))
+	for x := range out {
+		if outs[x] != nil {
+			out[x] = make([]byte, revBufSize)
+			sums[x] = crc32.NewIEEE()
+		}
+	}
+	buf := make([]byte, revBufSize)
+	syn := make([][]byte, len(rows))
+	for k := range syn {
+		syn[k] = make([]byte, revBufSize)
+	}
+	read := func(f fs.File, b []byte, l int) error {
+		c, err := io.ReadFull(f, b[:l])
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		clear(b[c:]) // volumes are padded with zeros
+		return nil
+	}
+	for off := int64(0); off < size; off += revBufSize {
+		l := int(min(revBufSize, rs.size-off))
+		lw := int(min(revBufSize, size-off)) // l rounded up to whole words
+		for k, r := range rows {
+			if err = read(srcs[r], syn[k][:lw], l); err != nil {
+				return nil, err
+			}
+		}
+		for j, f := range srcs[:rs.dataCount] {
+			if f == nil {
+				continue
+			}
+			if err = read(f, buf[:lw], l); err != nil {
+				return nil, err
+			}
+			for k, r := range rows {
+				gf16MulAdd(syn[k][:lw], buf[:lw], rev5Coef(r, j))
+			}
+		}
+		for x, dst := range out {
+			if dst == nil {
+				continue
+			}
+			dst = dst[:lw]
+			clear(dst)
+			for k, s := range syn {
+				gf16MulAdd(dst, s[:lw], inv[x][k])
+			}
+			// leave out the padding after the end of the volume
+			end := min(off+int64(lw), rs.vols[lost[x]].size)
+			if end <= off {
+				continue
+			}
+			dst = dst[:end-off]
+			_, _ = sums[x].Write(dst)
+			if _, err = outs[x].Write(dst); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for x, h := range sums {
+		if h != nil && h.Sum32() != rs.vols[lost[x]].crc {
+			return nil, ErrCorruptRecovery
+		}
+	}
+	return files, nil
+}
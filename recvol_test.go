@@ -0,0 +1,332 @@
+package rardecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// rsEncode returns the Reed-Solomon parity bytes for data, calculated with
+// a shift register as done when creating RAR 3.x recovery volumes.
+func rsEncode(data []byte, par int) []byte {
+	// generator polynomial with roots gfExp[1] .. gfExp[par]
+	g := make([]byte, par+1)
+	g[0] = 1
+	for i := 1; i <= par; i++ {
+		for j := par; j >= 0; j-- {
+			v := gfMul[gfExp[i]][g[j]]
+			if j > 0 {
+				v ^= g[j-1]
+			}
+			g[j] = v
+		}
+	}
+	reg := make([]byte, par)
+	for _, d := range data {
+		fb := d ^ reg[par-1]
+		for j := par - 1; j > 0; j-- {
+			reg[j] = reg[j-1] ^ gfMul[g[j]][fb]
+		}
+		reg[0] = gfMul[g[0]][fb]
+	}
+	out := make([]byte, par)
+	for j := range out {
+		out[j] = reg[par-1-j]
+	}
+	return out
+}
+
+// revVolumes returns RAR 3.x recovery volumes for the given data volumes.
+func revVolumes(vols [][]byte, count int) [][]byte {
+	size := 0
+	for _, v := range vols {
+		size = max(size, len(v))
+	}
+	revs := make([][]byte, count)
+	data := make([]byte, len(vols))
+	for i := 0; i < size; i++ {
+		for j, v := range vols {
+			data[j] = 0
+			if i < len(v) {
+				data[j] = v[i]
+			}
+		}
+		for r, p := range rsEncode(data, count) {
+			revs[r] = append(revs[r], p)
+		}
+	}
+	for r := range revs {
+		revs[r] = append(revs[r], byte(len(vols)-1), byte(count-1), byte(r))
+		revs[r] = binary.LittleEndian.AppendUint32(revs[r], crc32.ChecksumIEEE(revs[r]))
+	}
+	return revs
+}
+
+// rev5Volumes returns RAR 5 recovery volumes for the given data volumes.
+func rev5Volumes(vols [][]byte, count int) [][]byte {
+	gf16Once.Do(gf16Init)
+	size := 0
+	for _, v := range vols {
+		size = max(size, len(v))
+	}
+	size = (size + 1) &^ 1
+	revs := make([][]byte, count)
+	for r := range revs {
+		data := make([]byte, size)
+		for j, v := range vols {
+			padded := make([]byte, size)
+			copy(padded, v)
+			gf16MulAdd(data, padded, rev5Coef(len(vols)+r, j))
+		}
+		hdr := []byte{rev5Version}
+		hdr = binary.LittleEndian.AppendUint16(hdr, uint16(len(vols)))
+		hdr = binary.LittleEndian.AppendUint16(hdr, uint16(count))
+		hdr = binary.LittleEndian.AppendUint16(hdr, uint16(len(vols)+r))
+		hdr = binary.LittleEndian.AppendUint32(hdr, crc32.ChecksumIEEE(data))
+		for _, v := range vols {
+			hdr = binary.LittleEndian.AppendUint64(hdr, uint64(len(v)))
+			hdr = binary.LittleEndian.AppendUint32(hdr, crc32.ChecksumIEEE(v))
+		}
+		hdr = append(binary.LittleEndian.AppendUint32(nil, uint32(len(hdr))), hdr...)
+		b := append([]byte(rev5Sig), binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(hdr))...)
+		b = append(b, hdr...)
+		revs[r] = append(b, data...)
+	}
+	return revs
+}
+
+// multiVolumeArchive returns a RAR 5 archive with a stored file split across volumes.
+func multiVolumeArchive(contents []byte, parts int) [][]byte {
+	var vols [][]byte
+	size := (len(contents) + parts - 1) / parts
+	for i := 0; i < parts; i++ {
+		part := contents[i*size : min((i+1)*size, len(contents))]
+		var flags uint64
+		if i > 0 {
+			flags |= block5DataNotFirst
+		}
+		if i < parts-1 {
+			flags |= block5DataNotLast
+		}
+		arcFlags := uint64(arc5MultiVol)
+		var arc []byte
+		if i > 0 {
+			arcFlags |= arc5VolNum
+			arc = binary.AppendUvarint(arc, uint64(i))
+		}
+		arc = append(binary.AppendUvarint(nil, arcFlags), arc...)
+		fd := rar5FileData("data.bin", contents)
+		v := []byte(sig50)
+		v = append(v, rar5Block(block5Arc, 0, arc, nil, nil)...)
+		v = append(v, rar5Block(block5File, flags, fd, nil, part)...)
+		var end byte
+		if i < parts-1 {
+			end = endArc5NotLast
+		}
+		v = append(v, rar5Block(block5End, 0, []byte{end}, nil, nil)...)
+		vols = append(vols, v)
+	}
+	return vols
+}
+
+func TestReconstructVolume(t *testing.T) {
+	contents := make([]byte, 5000)
+	for i := range contents {
+		contents[i] = byte(i*13 + i>>8)
+	}
+	vols := multiVolumeArchive(contents, 4)
+	revs := revVolumes(vols, 2)
+
+	files := func(missing ...int) fstest.MapFS {
+		fsys := fstest.MapFS{}
+		for i, v := range vols {
+			fsys[fmt.Sprintf("arc.part%d.rar", i+1)] = &fstest.MapFile{Data: v}
+		}
+		for i, r := range revs {
+			fsys[fmt.Sprintf("arc.part%d.rev", i+1)] = &fstest.MapFile{Data: r}
+		}
+		for _, i := range missing {
+			delete(fsys, fmt.Sprintf("arc.part%d.rar", i+1))
+		}
+		return fsys
+	}
+
+	// rebuild an existing volume
+	for i := range vols {
+		var buf bytes.Buffer
+		err := ReconstructVolume("arc.part1.rar", i, &buf, FileSystem(files()))
+		if err != nil || !bytes.Equal(buf.Bytes(), vols[i]) {
+			t.Errorf("ReconstructVolume(%d) = %d bytes, %v, want %d bytes", i, buf.Len(), err, len(vols[i]))
+		}
+	}
+
+	// rebuild missing volumes while reading the archive
+	fsys := files(1, 3)
+	if _, err := OpenFS("arc.part1.rar", FileSystem(fsys)); err == nil {
+		t.Error("OpenFS() succeeded without recovery volumes")
+	}
+	if info, err := ListArchiveInfo("arc.part1.rar", FileSystem(fsys)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ListArchiveInfo() without recovery volumes = %d files, %v, want %v", len(info), err, fs.ErrNotExist)
+	}
+	damaged := bytes.Clone(revs[1])
+	damaged[0] ^= 0xff
+	fsys["arc.part2.rev"] = &fstest.MapFile{Data: damaged} // damaged recovery volumes are ignored
+	if _, err := OpenFS("arc.part1.rar", FileSystem(fsys), RecoveryVolumes); err != ErrTooManyMissingVolumes {
+		t.Errorf("OpenFS() error = %v, want %v", err, ErrTooManyMissingVolumes)
+	}
+	fsys = files(1, 3)
+	rfs, err := OpenFS("arc.part1.rar", FileSystem(fsys), RecoveryVolumes)
+	if err != nil {
+		t.Fatalf("OpenFS() error = %v", err)
+	}
+	b, err := rfs.ReadFile("data.bin")
+	if err != nil || !bytes.Equal(b, contents) {
+		t.Errorf("ReadFile() = %d bytes, %v, want %d bytes", len(b), err, len(contents))
+	}
+
+	// too many missing volumes
+	err = ReconstructVolume("arc.part1.rar", 0, io.Discard, FileSystem(files(1, 2)))
+	if err != ErrTooManyMissingVolumes {
+		t.Errorf("ReconstructVolume() error = %v, want %v", err, ErrTooManyMissingVolumes)
+	}
+}
+
+func TestReconstructVolume5(t *testing.T) {
+	contents := make([]byte, 7001)
+	for i := range contents {
+		contents[i] = byte(i*29 + i>>7)
+	}
+	vols := multiVolumeArchive(contents, 5)
+	vols[4] = append(vols[4], 0) // odd sized last volume
+	revs := rev5Volumes(vols, 3)
+
+	files := func(missing ...int) fstest.MapFS {
+		fsys := fstest.MapFS{}
+		for i, v := range vols {
+			fsys[fmt.Sprintf("arc.part%d.rar", i+1)] = &fstest.MapFile{Data: v}
+		}
+		for i, r := range revs {
+			fsys[fmt.Sprintf("arc.part%d.rev", i+1)] = &fstest.MapFile{Data: r}
+		}
+		for _, i := range missing {
+			delete(fsys, fmt.Sprintf("arc.part%d.rar", i+1))
+		}
+		return fsys
+	}
+	for i := range vols {
+		var buf bytes.Buffer
+		err := ReconstructVolume("arc.part1.rar", i, &buf, FileSystem(files()))
+		if err != nil || !bytes.Equal(buf.Bytes(), vols[i]) {
+			t.Errorf("ReconstructVolume(%d) = %d bytes, %v, want %d bytes", i, buf.Len(), err, len(vols[i]))
+		}
+	}
+
+	// missing volumes and a damaged volume, which doesn't match its checksum
+	fsys := files(1, 3)
+	damaged := bytes.Clone(vols[2])
+	damaged[len(damaged)-20] ^= 0xff
+	fsys["arc.part3.rar"] = &fstest.MapFile{Data: damaged}
+	var buf bytes.Buffer
+	err := ReconstructVolume("arc.part1.rar", 1, &buf, FileSystem(fsys))
+	if err != nil || !bytes.Equal(buf.Bytes(), vols[1]) {
+		t.Errorf("ReconstructVolume(1) = %d bytes, %v, want %d bytes", buf.Len(), err, len(vols[1]))
+	}
+	err = ReconstructVolume("arc.part1.rar", 4, io.Discard, FileSystem(files(0, 1, 2)))
+	if err != ErrTooManyMissingVolumes {
+		t.Errorf("ReconstructVolume() error = %v, want %v", err, ErrTooManyMissingVolumes)
+	}
+
+	fsys = files(1, 3)
+	rfs, err := OpenFS("arc.part1.rar", FileSystem(fsys), RecoveryVolumes)
+	if err != nil {
+		t.Fatalf("OpenFS() error = %v", err)
+	}
+	b, err := rfs.ReadFile("data.bin")
+	if err != nil || !bytes.Equal(b, contents) {
+		t.Errorf("ReadFile() = %d bytes, %v, want %d bytes", len(b), err, len(contents))
+	}
+
+	// damaged recovery volumes are ignored
+	revs[0][len(revs[0])-1] ^= 0xff
+	err = ReconstructVolume("arc.part1.rar", 0, io.Discard, FileSystem(files(1, 2, 3)))
+	if err != ErrTooManyMissingVolumes {
+		t.Errorf("ReconstructVolume() with a damaged recovery volume error = %v, want %v", err, ErrTooManyMissingVolumes)
+	}
+}
+
+func TestGF16(t *testing.T) {
+	gf16Once.Do(gf16Init)
+	for _, a := range []uint16{1, 2, 3, 0x8000, 0x1234, 0xffff} {
+		if p := gf16Mul(a, gf16Inv(a)); p != 1 {
+			t.Errorf("%#x * inverse = %#x, want 1", a, p)
+		}
+	}
+	// x^16 reduced by the field polynomial x^16 + x^12 + x^3 + x + 1
+	if p := gf16Mul(0x8000, 2); p != 0x100b {
+		t.Errorf("x^15 * x = %#x, want %#x", p, 0x100b)
+	}
+}
+
+// chunkWriter records the largest write made to it.
+type chunkWriter struct {
+	bytes.Buffer
+	writes int
+	max    int
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.writes++
+	w.max = max(w.max, len(p))
+	return w.Buffer.Write(p)
+}
+
+func TestReconstructVolumeChunks(t *testing.T) {
+	contents := make([]byte, 5*revBufSize)
+	for i := range contents {
+		contents[i] = byte(i*7 + 1)
+	}
+	// zeros across a chunk boundary are written, unlike the padding of the last volume
+	clear(contents[revBufSize-10 : 2*revBufSize+10])
+	vols := multiVolumeArchive(contents, 3)
+	for _, tt := range []struct {
+		name string
+		revs [][]byte
+	}{
+		{"rar3", revVolumes(vols, 2)},
+		{"rar5", rev5Volumes(vols, 2)},
+	} {
+		fsys := fstest.MapFS{}
+		for i, v := range vols {
+			if i != 1 {
+				fsys[fmt.Sprintf("arc.part%d.rar", i+1)] = &fstest.MapFile{Data: v}
+			}
+		}
+		for i, r := range tt.revs {
+			fsys[fmt.Sprintf("arc.part%d.rev", i+1)] = &fstest.MapFile{Data: r}
+		}
+		for i := range vols {
+			var w chunkWriter
+			err := ReconstructVolume("arc.part1.rar", i, &w, FileSystem(fsys))
+			if err != nil || !bytes.Equal(w.Bytes(), vols[i]) {
+				t.Errorf("%s: ReconstructVolume(%d) = %d bytes, %v, want %d bytes", tt.name, i, w.Len(), err, len(vols[i]))
+			}
+			if w.writes < 2 || w.max > revBufSize {
+				t.Errorf("%s: ReconstructVolume(%d) made %d writes of up to %d bytes, want chunks of %d bytes", tt.name, i, w.writes, w.max, revBufSize)
+			}
+		}
+		rfs, err := OpenFS("arc.part1.rar", FileSystem(fsys), RecoveryVolumes)
+		if err != nil {
+			t.Fatalf("%s: OpenFS() error = %v", tt.name, err)
+		}
+		b, err := rfs.ReadFile("data.bin")
+		if err != nil || !bytes.Equal(b, contents) {
+			t.Errorf("%s: ReadFile() = %d bytes, %v, want %d bytes", tt.name, len(b), err, len(contents))
+		}
+	}
+}
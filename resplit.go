@@ -0,0 +1,56 @@
+package rardecode
+
+// Resplit copies the files of the RAR archive src into a new RAR 5 archive with
+// volumes of at most volumeSize bytes, named by dst. If volumeSize is 0, the
+// volumes of src are joined into a single volume archive named dst(0).
+//
+// The packed data of each file is copied from the volumes of src without being
+// decoded, while the block headers are written again with new continuation flags,
+// volume numbers and checksums for each part of a file. Solid and encrypted files
+// are copied unchanged. If the headers of src are encrypted, the Password option
+// must be given and the headers of the new archive are encrypted with it. Encrypted
+// files with MAC checksums also need the Password option to be split into volumes.
+// Options are used for reading src and writing the new archive.
+//
+// Archive comments and service data of files, such as NTFS security descriptors,
+// alternate data streams and unix owner names, aren't copied, so archives that
+// have them return ErrCopyUnsupported. Recovery records aren't copied, as they
+// wouldn't match the new volumes. Archives containing files compressed by RAR
+// versions before 5.0, or encrypted by them, also return ErrCopyUnsupported.
+func Resplit(src string, dst VolumeNamer, volumeSize int64, opts ...Option) error {
+	vm, files, err := listFileBlocks(src, opts)
+	if err != nil {
+		return err
+	}
+	cmt, err := vm.comment()
+	if err != nil {
+		return err
+	}
+	if cmt != "" {
+		return ErrCopyUnsupported
+	}
+	for _, blocks := range files {
+		if blocks.firstBlock().services {
+			return ErrCopyUnsupported
+		}
+	}
+	opt := getOptions(opts)
+	opt.volSize = volumeSize
+	var solid bool
+	if hdr := vm.archiveHeader(); hdr != nil {
+		solid = hdr.Solid
+		opt.encHeaders = hdr.HeaderEncrypted
+	}
+	w, err := createWriter(dst, opt, solid)
+	if err != nil {
+		return err
+	}
+	for _, blocks := range files {
+		err = w.copyFile(vm, blocks, true)
+		if err != nil {
+			w.Close()
+			return err
+		}
+	}
+	return w.Close()
+}
@@ -0,0 +1,136 @@
+package rardecode
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestResplit(t *testing.T) {
+	big := make([]byte, 4000)
+	for i := range big {
+		big[i] = byte(i * 5)
+	}
+	mtime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	files := []writeTestFile{
+		{name: "dir", dir: true, mtime: mtime},
+		{name: "dir/a.txt", contents: []byte("hello"), mtime: mtime},
+		{name: "big.bin", contents: big, mtime: mtime},
+	}
+	dir := t.TempDir()
+	pass := Password("secret")
+
+	// join a volume set into a single volume
+	src := filepath.Join(dir, "src.rar")
+	writeTestArchive(t, src, files, VolumeSize(1024))
+	joined := filepath.Join(dir, "joined.rar")
+	err := Resplit(volumeName(src, 0), func(int) string { return joined }, 0)
+	if err != nil {
+		t.Fatalf("Resplit error = %v", err)
+	}
+	checkTestArchive(t, joined, files, HashCRC32)
+
+	// split it again with a different volume size
+	split := filepath.Join(dir, "split.rar")
+	err = Resplit(joined, PartVolumeNamer(split), 3000)
+	if err != nil {
+		t.Fatalf("Resplit error = %v", err)
+	}
+	if _, err = os.Stat(volumeName(split, 1)); err != nil {
+		t.Errorf("Stat volume 2 error = %v", err)
+	}
+	if _, err = os.Stat(volumeName(split, 2)); !os.IsNotExist(err) {
+		t.Errorf("Stat volume 3 error = %v, want not exist", err)
+	}
+	checkTestArchive(t, volumeName(split, 0), files, HashCRC32)
+
+	// encrypted headers are encrypted again, the solid flag is kept
+	opt := getOptions([]Option{pass, EncryptHeaders, UseBLAKE2sp, VolumeSize(1500)})
+	src = filepath.Join(dir, "enc.rar")
+	w, err := createWriter(PartVolumeNamer(src), opt, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		fw, err := w.CreateHeader(&FileHeader{Name: f.name, IsDir: f.dir, UnPackedSize: int64(len(f.contents)), ModificationTime: f.mtime})
+		if err == nil {
+			_, err = fw.Write(f.contents)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = Resplit(volumeName(src, 0), func(int) string { return joined }, 0); err != ErrArchiveEncrypted {
+		t.Errorf("Resplit without password error = %v, want %v", err, ErrArchiveEncrypted)
+	}
+	if err = Resplit(volumeName(src, 0), func(int) string { return joined }, 0, pass); err != nil {
+		t.Fatalf("Resplit error = %v", err)
+	}
+	checkTestArchive(t, joined, files, HashBLAKE2sp, pass)
+	rc, err := OpenReader(joined, pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if hdr := rc.ArchiveHeader(); !hdr.Solid || !hdr.HeaderEncrypted || hdr.MultiVolume {
+		t.Errorf("archive header = %+v, want solid, encrypted single volume", hdr)
+	}
+
+	// MAC checksums of split files need the password
+	src = filepath.Join(dir, "mac.rar")
+	writeTestArchive(t, src, files, pass)
+	split = filepath.Join(dir, "macsplit.rar")
+	if err = Resplit(src, PartVolumeNamer(split), 1500); err != ErrCopyUnsupported {
+		t.Errorf("Resplit without password error = %v, want %v", err, ErrCopyUnsupported)
+	}
+	if err = Resplit(src, PartVolumeNamer(split), 1500, pass); err != nil {
+		t.Fatalf("Resplit error = %v", err)
+	}
+	checkTestArchive(t, volumeName(split, 0), files, HashCRC32, pass)
+	checkPartHashes(t, volumeName(split, 0), pass)
+}
+
+func TestResplitServiceData(t *testing.T) {
+	file := rar5Block(block5File, 0, rar5FileData("a.txt", []byte("a")), nil, []byte("a"))
+	service := func(name string) []byte {
+		return rar5Block(block5Service, 0, rar5FileData(name, []byte("data")), nil, []byte("data"))
+	}
+	archive := func(blocks ...[]byte) *fstest.MapFile {
+		arc := []byte(sig50)
+		arc = append(arc, rar5Block(block5Arc, 0, []byte{0}, nil, nil)...)
+		for _, b := range blocks {
+			arc = append(arc, b...)
+		}
+		arc = append(arc, rar5Block(block5End, 0, []byte{0}, nil, nil)...)
+		return &fstest.MapFile{Data: arc}
+	}
+	fsys := fstest.MapFS{
+		"comment.rar":  archive(service(serviceComment), file),
+		"acl.rar":      archive(file, service(serviceACL)),
+		"stream.rar":   archive(file, service(serviceStream)),
+		"recovery.rar": archive(file, service(serviceRecovery)),
+	}
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"comment.rar", ErrCopyUnsupported},
+		{"acl.rar", ErrCopyUnsupported},
+		{"stream.rar", ErrCopyUnsupported},
+		{"recovery.rar", nil}, // the recovery record is dropped
+	}
+	for _, tt := range tests {
+		dst := filepath.Join(dir, tt.name)
+		err := Resplit(tt.name, func(int) string { return dst }, 0, FileSystem(fsys))
+		if err != tt.err {
+			t.Errorf("Resplit(%s) error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+	checkTestArchive(t, filepath.Join(dir, "recovery.rar"), []writeTestFile{{name: "a.txt", contents: []byte("a")}}, HashCRC32)
+}
@@ -0,0 +1,108 @@
+package rardecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const maxSecurityDescriptorSize = 0x10000 // maximum size of a security descriptor read from an ACL block
+
+// sidString returns the string form of the SID at offset off in the
+// self-relative security descriptor b, or an empty string if there is none.
+func sidString(b []byte, off uint32) string {
+	if off == 0 || int64(off)+8 > int64(len(b)) {
+		return ""
+	}
+	sid := b[off:]
+	n := int(sid[1]) // number of sub authorities
+	if sid[0] != 1 || len(sid) < 8+n*4 {
+		return ""
+	}
+	var auth uint64
+	for _, c := range sid[2:8] {
+		auth = auth<<8 | uint64(c)
+	}
+	var s strings.Builder
+	s.WriteString("S-1-")
+	if auth >= 1<<32 {
+		fmt.Fprintf(&s, "0x%012X", auth)
+	} else {
+		s.WriteString(strconv.FormatUint(auth, 10))
+	}
+	for i := 0; i < n; i++ {
+		s.WriteByte('-')
+		s.WriteString(strconv.FormatUint(uint64(binary.LittleEndian.Uint32(sid[8+i*4:])), 10))
+	}
+	return s.String()
+}
+
+// parseSecurityDescriptor sets the security descriptor of f and the owner and
+// group SIDs stored in it.
+func parseSecurityDescriptor(f *FileHeader, b []byte) {
+	f.SecurityDescriptor = b
+	if len(b) < 20 || b[0] != 1 {
+		return
+	}
+	f.OwnerSID = sidString(b, binary.LittleEndian.Uint32(b[4:]))
+	f.GroupSID = sidString(b, binary.LittleEndian.Uint32(b[8:]))
+}
+
+// parseUnixOwner sets the owner and group names of f from the service data of
+// a RAR 3.x UOW block, which stores them as two null terminated strings.
+func parseUnixOwner(f *FileHeader, b []byte) {
+	user, group, ok := bytes.Cut(b, []byte{0})
+	if !ok {
+		return
+	}
+	group, _, _ = bytes.Cut(group, []byte{0})
+	f.UserName = string(user)
+	f.GroupName = string(group)
+	f.HasUserName = true
+	f.HasGroupName = true
+}
+
+// serviceHeader returns the header holding the file metadata read from the
+// service blocks following f. It is kept apart from the FileHeader of f, which
+// may already have been returned by Reader.Next.
+func (f *fileBlockHeader) serviceHeader() *FileHeader {
+	if f.meta == nil {
+		f.meta = new(FileHeader)
+	}
+	return f.meta
+}
+
+// readFileService reads file metadata from the service block h for the file block f.
+// Metadata that can't be read is ignored as it isn't needed to extract the file.
+// ACL blocks are only decoded if the SecurityDescriptors option is set.
+func (v *readerVolume) readFileService(f, h *fileBlockHeader) {
+	switch h.service {
+	case serviceComment, serviceRecovery, serviceQuickOpen:
+		// archive service blocks
+		return
+	}
+	f.serviceHeader() // record that the file has service data
+	switch h.service {
+	case serviceUnixOwner:
+		parseUnixOwner(f.serviceHeader(), h.subData)
+	case serviceACL:
+		if !v.opt.securityDescriptors || !h.first || !h.last || h.UnKnownSize ||
+			h.UnPackedSize > maxSecurityDescriptorSize {
+			return
+		}
+		// service blocks don't depend on the preceding solid files
+		h.Solid = false
+		pr := &packedFileReader{v: v, opt: v.opt}
+		r, err := pr.newArchiveFile(newFileBlockList(h))
+		if err != nil {
+			return
+		}
+		b, err := io.ReadAll(r)
+		if err == nil {
+			parseSecurityDescriptor(f.serviceHeader(), b)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package rardecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFileServiceData(t *testing.T) {
+	// security descriptor with owner S-1-5-32-544 and group S-1-5-18
+	sd := []byte{1, 0, 0x04, 0x80}
+	sd = binary.LittleEndian.AppendUint32(sd, 20) // owner offset
+	sd = binary.LittleEndian.AppendUint32(sd, 36) // group offset
+	sd = binary.LittleEndian.AppendUint32(sd, 0)  // sacl offset
+	sd = binary.LittleEndian.AppendUint32(sd, 0)  // dacl offset
+	sd = append(sd, 1, 2, 0, 0, 0, 0, 0, 5)
+	sd = binary.LittleEndian.AppendUint32(sd, 32)
+	sd = binary.LittleEndian.AppendUint32(sd, 544)
+	sd = append(sd, 1, 1, 0, 0, 0, 0, 0, 5)
+	sd = binary.LittleEndian.AppendUint32(sd, 18)
+
+	arc50 := []byte(sig50)
+	arc50 = append(arc50, rar5Block(block5Arc, 0, []byte{0}, nil, nil)...)
+	arc50 = append(arc50, rar5Block(block5File, 0, rar5FileData("a.txt", []byte("a")), nil, []byte("a"))...)
+	arc50 = append(arc50, rar5Block(block5Service, 0, rar5FileData(serviceACL, sd), nil, sd)...)
+	arc50 = append(arc50, rar5Block(block5File, 0, rar5FileData("b.txt", []byte("b")), nil, []byte("b"))...)
+	arc50 = append(arc50, rar5Block(block5End, 0, []byte{0}, nil, nil)...)
+
+	uow := append(rar15File(serviceUnixOwner, nil), "alice\x00staff\x00"...)
+	arc30 := []byte(sig15)
+	arc30 = append(arc30, rar15Block(blockArc, 0, make([]byte, 6), 0)...)
+	arc30 = append(arc30, rar15Block(blockFile, blockHasData, rar15File("a.txt", []byte("a")), 0)...)
+	arc30 = append(arc30, 'a')
+	arc30 = append(arc30, rar15Block(blockService, blockHasData, rar15File(serviceACL, sd), 0)...)
+	arc30 = append(arc30, sd...)
+	arc30 = append(arc30, rar15Block(blockService, blockHasData, uow, 0)...)
+	arc30 = append(arc30, rar15Block(blockFile, blockHasData, rar15File("b.txt", []byte("b")), 0)...)
+	arc30 = append(arc30, 'b')
+	arc30 = append(arc30, rar15Block(blockEnd, 0, nil, 0)...)
+
+	fsys := fstest.MapFS{
+		"arc50.rar": {Data: arc50},
+		"arc30.rar": {Data: arc30},
+	}
+	tests := []struct {
+		name string
+		opts []Option
+		user string
+	}{
+		{"arc50.rar", []Option{SecurityDescriptors}, ""},
+		{"arc30.rar", []Option{SecurityDescriptors}, "alice"},
+		{"arc30.rar", []Option{SecurityDescriptors, OpenFSCheck}, "alice"},
+		{"arc30.rar", []Option{SecurityDescriptors, ParallelRead(true)}, "alice"},
+	}
+	for _, tt := range tests {
+		files, err := List(tt.name, append(tt.opts, FileSystem(fsys))...)
+		if err != nil {
+			t.Fatalf("List(%s) error = %v", tt.name, err)
+		}
+		if len(files) != 2 {
+			t.Fatalf("List(%s) returned %d files, want 2", tt.name, len(files))
+		}
+		h := files[0].FileHeader
+		if !bytes.Equal(h.SecurityDescriptor, sd) || h.OwnerSID != "S-1-5-32-544" || h.GroupSID != "S-1-5-18" {
+			t.Errorf("%s security descriptor = %x, owner %q, group %q", tt.name, h.SecurityDescriptor, h.OwnerSID, h.GroupSID)
+		}
+		if h.HasUserName != (tt.user != "") || h.UserName != tt.user {
+			t.Errorf("%s user name = %q, want %q", tt.name, h.UserName, tt.user)
+		}
+		if tt.user != "" && (!h.HasGroupName || h.GroupName != "staff") {
+			t.Errorf("%s group name = %q, want %q", tt.name, h.GroupName, "staff")
+		}
+		if h = files[1].FileHeader; h.SecurityDescriptor != nil || h.HasUserName {
+			t.Errorf("%s: %s has unexpected owner data", tt.name, h.Name)
+		}
+	}
+
+	// ACL blocks aren't decoded unless asked for
+	files, err := List("arc30.rar", FileSystem(fsys))
+	if err != nil {
+		t.Fatalf("List error = %v", err)
+	}
+	if h := files[0].FileHeader; h.SecurityDescriptor != nil || h.UserName != "alice" {
+		t.Errorf("List without SecurityDescriptors: security descriptor = %x, user name %q", h.SecurityDescriptor, h.UserName)
+	}
+
+	// the header returned by Reader.Next isn't changed by the following service blocks
+	r, err := NewReader(bytes.NewReader(arc30), SecurityDescriptors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if h.SecurityDescriptor != nil || h.HasUserName {
+		t.Errorf("Reader header of %s changed after Next", h.Name)
+	}
+}
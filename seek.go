@@ -0,0 +1,179 @@
+package rardecode
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// decodeCursor is a position in the decoded contents of a file.
+type decodeCursor struct {
+	f   archiveFile
+	cl  io.Closer
+	off int64 // offset of f in the decoded file contents
+}
+
+func (c *decodeCursor) close() error {
+	if c.cl == nil {
+		return nil
+	}
+	err := c.cl.Close()
+	c.f, c.cl = nil, nil
+	return err
+}
+
+// seekFile implements io.Seeker and io.ReaderAt for files that can only be read
+// sequentially, such as compressed files. Seeking forwards decodes and discards
+// the data up to the new offset, while seeking backwards restarts decoding from
+// the beginning of the file. Seek only records the new offset, the decoding is
+// done by the next Read.
+type seekFile struct {
+	vm     *volumeManager
+	blocks *fileBlockList
+	cur    decodeCursor
+	pos    int64 // offset for the next Read
+	size   int64 // decoded file size, or -1 if not known yet
+
+	mu sync.Mutex   // protects at
+	at decodeCursor // cursor used by ReadAt so it doesn't affect the Read offset
+}
+
+// moveTo positions c at offset off, reopening the file if off is before the
+// current position of c.
+func (sf *seekFile) moveTo(c *decodeCursor, off int64) error {
+	if c.f == nil || off < c.off {
+		c.close()
+		f, v, err := sf.vm.openFileReader(sf.blocks)
+		if err != nil {
+			return err
+		}
+		c.f, c.cl, c.off = f, v, 0
+	}
+	if off > c.off {
+		n, err := io.CopyN(io.Discard, c.f, off-c.off)
+		c.off += n
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// fileSize returns the decoded size of the file, decoding it with c if the size
+// isn't stored in the file header.
+func (sf *seekFile) fileSize(c *decodeCursor) (int64, error) {
+	if sf.size >= 0 {
+		return sf.size, nil
+	}
+	err := sf.moveTo(c, c.off)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(io.Discard, c.f)
+	c.off += n
+	if err != nil {
+		return 0, err
+	}
+	sf.size = c.off
+	return sf.size, nil
+}
+
+func (sf *seekFile) Read(p []byte) (int, error) {
+	err := sf.moveTo(&sf.cur, sf.pos)
+	if err != nil {
+		return 0, err
+	}
+	if sf.cur.off < sf.pos {
+		return 0, io.EOF
+	}
+	n, err := sf.cur.f.Read(p)
+	sf.cur.off += int64(n)
+	sf.pos = sf.cur.off
+	return n, err
+}
+
+func (sf *seekFile) WriteTo(w io.Writer) (int64, error) {
+	err := sf.moveTo(&sf.cur, sf.pos)
+	if err != nil || sf.cur.off < sf.pos {
+		return 0, err
+	}
+	n, err := sf.cur.f.WriteTo(w)
+	sf.cur.off += n
+	sf.pos = sf.cur.off
+	return n, err
+}
+
+func (sf *seekFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += sf.pos
+	case io.SeekEnd:
+		size, err := sf.fileSize(&sf.cur)
+		if err != nil {
+			return 0, err
+		}
+		offset += size
+	default:
+		return 0, fs.ErrInvalid
+	}
+	if offset < 0 {
+		return 0, fs.ErrInvalid
+	}
+	sf.pos = offset
+	return offset, nil
+}
+
+// ReadAt reads len(p) bytes from the file starting at offset off.
+// ReadAt doesn't change the offset used by Read and Seek. Concurrent
+// calls to ReadAt are serialized.
+func (sf *seekFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fs.ErrInvalid
+	}
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	err := sf.moveTo(&sf.at, off)
+	if err != nil {
+		return 0, err
+	}
+	if sf.at.off < off {
+		return 0, io.EOF
+	}
+	n, err := io.ReadFull(sf.at.f, p)
+	sf.at.off += int64(n)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (sf *seekFile) Stat() (fs.FileInfo, error) {
+	return fileInfo{h: sf.blocks.firstBlock()}, nil
+}
+
+func (sf *seekFile) Close() error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	err := sf.cur.close()
+	if aerr := sf.at.close(); err == nil {
+		err = aerr
+	}
+	return err
+}
+
+func newSeekFile(vm *volumeManager, blocks *fileBlockList, f archiveFile, v io.Closer) *seekFile {
+	h := blocks.firstBlock()
+	size := h.UnPackedSize
+	if h.UnKnownSize {
+		size = -1
+	}
+	return &seekFile{
+		vm:     vm,
+		blocks: blocks,
+		cur:    decodeCursor{f: f, cl: v},
+		size:   size,
+	}
+}
@@ -0,0 +1,84 @@
+package rardecode
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+	"testing/fstest"
+)
+
+// rar15Packed returns a RAR 3.x file block with the RAR 1.5 compressed data packed,
+// which decodes to contents.
+func rar15Packed(name, contents string, packed []byte) []byte {
+	return rar15PackedFlags(name, contents, packed, 0)
+}
+
+// rar15PackedFlags is rar15Packed with additional block flags, such as fileSolid.
+func rar15PackedFlags(name, contents string, packed []byte, flags uint16) []byte {
+	hdr := rar15File(name, []byte(contents))
+	binary.LittleEndian.PutUint32(hdr, uint32(len(packed)))
+	hdr[17], hdr[18] = 15, 0x33 // unpack version, method
+	return append(rar15Block(blockFile, blockHasData|flags, hdr, 0), packed...)
+}
+
+func TestSeekCompressedFile(t *testing.T) {
+	// RAR 1.5 compressed file, see TestDecoder15
+	want := "ABABABABABABA"
+	arc := []byte(sig15)
+	arc = append(arc, rar15Block(blockArc, 0, make([]byte, 6), 0)...)
+	arc = append(arc, rar15Packed("a.txt", want, []byte{0x77, 0xcb, 0xcd, 0x00, 0x40})...)
+	arc = append(arc, rar15Block(blockEnd, 0, nil, 0)...)
+
+	rfs, err := OpenFS("arc.rar", FileSystem(fstest.MapFS{"arc.rar": {Data: arc}}))
+	if err != nil {
+		t.Fatalf("OpenFS error = %v", err)
+	}
+	f, err := rfs.Open("a.txt")
+	if err != nil {
+		t.Fatalf("Open error = %v", err)
+	}
+	defer f.Close()
+	sr, ok := f.(io.ReadSeeker)
+	if !ok {
+		t.Fatal("compressed file doesn't implement io.Seeker")
+	}
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		t.Fatal("compressed file doesn't implement io.ReaderAt")
+	}
+
+	buf := make([]byte, 4)
+	for _, tt := range []struct {
+		offset int64
+		whence int
+		pos    int64
+	}{
+		{5, io.SeekStart, 5},
+		{-4, io.SeekEnd, 9},
+		{1, io.SeekStart, 1}, // backwards seek restarts decoding
+		{2, io.SeekCurrent, 7},
+	} {
+		pos, err := sr.Seek(tt.offset, tt.whence)
+		if err != nil || pos != tt.pos {
+			t.Fatalf("Seek(%d, %d) = %d, %v, want %d", tt.offset, tt.whence, pos, err, tt.pos)
+		}
+		n, err := io.ReadFull(sr, buf)
+		if err != nil || string(buf[:n]) != want[pos:pos+4] {
+			t.Errorf("read at %d = %q, %v, want %q", pos, buf[:n], err, want[pos:pos+4])
+		}
+	}
+
+	n, err := ra.ReadAt(buf, 10)
+	if n != 3 || err != io.EOF || string(buf[:n]) != want[10:] {
+		t.Errorf("ReadAt(10) = %q, %v, want %q, EOF", buf[:n], err, want[10:])
+	}
+	n, err = ra.ReadAt(buf, 2)
+	if err != nil || string(buf[:n]) != want[2:6] {
+		t.Errorf("ReadAt(2) = %q, %v, want %q", buf[:n], err, want[2:6])
+	}
+	// ReadAt doesn't change the Read offset
+	b, err := io.ReadAll(sr)
+	if err != nil || string(b) != want[11:] {
+		t.Errorf("read after ReadAt = %q, %v, want %q", b, err, want[11:])
+	}
+}
@@ -0,0 +1,125 @@
+package rardecode
+
+import (
+	"io"
+	"sync"
+)
+
+// solidPos is the position of a file in a solid group.
+type solidPos struct {
+	group []*fileBlockList // files decoded in order by a single decoder
+	index int              // index of the file in group
+}
+
+// solidCache allows solid files to be opened individually by decoding the files
+// before them in their solid group. The decoder left at the end of the last file
+// read is kept, so reading the files of a solid archive in order only decodes
+// each file once.
+type solidCache struct {
+	files map[*fileBlockList]solidPos
+
+	mu    sync.Mutex
+	dr    *decodeReader    // decoder positioned at the start of group[next]
+	group []*fileBlockList // solid group of dr
+	next  int              // index of the next file in group dr can decode
+}
+
+// take removes the cached decoder if it can be used to decode file index of
+// group, returning it with the index of the next file it can decode.
+func (sc *solidCache) take(group []*fileBlockList, index int) (*decodeReader, int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.dr == nil || &sc.group[0] != &group[0] || sc.next > index {
+		return nil, 0
+	}
+	dr, next := sc.dr, sc.next
+	sc.dr, sc.group = nil, nil
+	return dr, next
+}
+
+// put caches dr, which is positioned at the start of file next of group.
+func (sc *solidCache) put(dr *decodeReader, group []*fileBlockList, next int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.dr, sc.group, sc.next = dr, group, next
+}
+
+func newSolidCache(files []*fileBlockList) *solidCache {
+	sc := &solidCache{files: map[*fileBlockList]solidPos{}}
+	for _, group := range solidGroups(files) {
+		for i, blocks := range group {
+			sc.files[blocks] = solidPos{group: group, index: i}
+		}
+	}
+	return sc
+}
+
+// solidCloser closes a solid file, caching its decoder if the file was read
+// to the end so the next file in the solid group can be opened without
+// decoding the preceding files again.
+type solidCloser struct {
+	f   archiveFile
+	pr  *packedFileReader
+	v   *fileVolume
+	sc  *solidCache
+	pos solidPos
+}
+
+func (c *solidCloser) Close() error {
+	if _, err := c.f.ReadByte(); err == io.EOF {
+		c.sc.put(c.pr.dr, c.pos.group, c.pos.index+1)
+	}
+	return c.v.Close()
+}
+
+// skipSolidFile decodes and discards the contents of the solid file in blocks
+// using dr, returning the decoder positioned at the start of the next file.
+// File checksums aren't checked as the contents aren't used.
+func (vm *volumeManager) skipSolidFile(blocks *fileBlockList, dr *decodeReader) (*decodeReader, error) {
+	v, err := vm.openBlockOffset(blocks.firstBlock(), 0)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Close()
+	opt := *vm.opt
+	opt.skipCheck = true
+	pr := &packedFileReader{v: v, opt: &opt, dr: dr}
+	f, err := pr.newArchiveFile(blocks)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(io.Discard, f)
+	if err != nil {
+		return nil, err
+	}
+	return pr.dr, nil
+}
+
+// openSolidFile opens the file in blocks from a solid archive, decoding the files
+// before it in its solid group that haven't already been decoded by the cached decoder.
+func (vm *volumeManager) openSolidFile(blocks *fileBlockList) (archiveFile, io.Closer, error) {
+	sc := vm.solid
+	pos, ok := sc.files[blocks]
+	if !ok {
+		return nil, nil, ErrSolidOpen
+	}
+	dr, next := sc.take(pos.group, pos.index)
+	var err error
+	for ; next < pos.index; next++ {
+		dr, err = vm.skipSolidFile(pos.group[next], dr)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	v, err := vm.openBlockOffset(blocks.firstBlock(), 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	pr := &packedFileReader{v: v, opt: vm.opt, dr: dr}
+	f, err := pr.newArchiveFile(blocks)
+	if err != nil {
+		v.Close()
+		return nil, nil, err
+	}
+	return f, &solidCloser{f: f, pr: pr, v: v, sc: sc, pos: pos}, nil
+}
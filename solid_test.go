@@ -0,0 +1,103 @@
+package rardecode
+
+import (
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"unicode/utf16"
+)
+
+func TestOpenSolidFile(t *testing.T) {
+	// RAR 1.5 solid files, see TestDecoder15
+	arc := []byte(sig15)
+	arc = append(arc, rar15Block(blockArc, arcSolid, make([]byte, 6), 0)...)
+	arc = append(arc, rar15Packed("a.txt", "ABBB", []byte{0x8b, 0xe5, 0xe6, 0x00})...)
+	arc = append(arc, rar15Packed("b.txt", "ABBB", []byte{0x00, 0x02, 0x00})...)
+	arc = append(arc, rar15Block(blockEnd, 0, nil, 0)...)
+	fsys := fstest.MapFS{"arc.rar": {Data: arc}}
+
+	rfs, err := OpenFS("arc.rar", FileSystem(fsys))
+	if err != nil {
+		t.Fatalf("OpenFS error = %v", err)
+	}
+	sc := rfs.vm.solid
+	// b.txt is opened first, so a.txt must be decoded to open it. After reading
+	// a.txt the cached decoder is used to open b.txt.
+	for _, tt := range []struct {
+		name string
+		next int
+	}{{"b.txt", 2}, {"a.txt", 1}, {"b.txt", 2}} {
+		b, err := fs.ReadFile(rfs, tt.name)
+		if err != nil || string(b) != "ABBB" {
+			t.Errorf("ReadFile(%s) = %q, %v, want %q", tt.name, b, err, "ABBB")
+		}
+		if sc.dr == nil || sc.next != tt.next {
+			t.Errorf("cached decoder after reading %s is at file %d, want %d", tt.name, sc.next, tt.next)
+		}
+	}
+
+	files, err := List("arc.rar", FileSystem(fsys))
+	if err != nil {
+		t.Fatalf("List error = %v", err)
+	}
+	if !files[1].Solid {
+		t.Fatal("b.txt isn't solid")
+	}
+	r, err := files[1].Open()
+	if err != nil {
+		t.Fatalf("Open(b.txt) error = %v", err)
+	}
+	b, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(b) != "ABBB" {
+		t.Errorf("read b.txt = %q, %v, want %q", b, err, "ABBB")
+	}
+}
+
+func TestOpenSolidStream(t *testing.T) {
+	// RAR 1.5 compressed stream between files of a solid archive, see TestDecoder15
+	svc := rar15File(serviceStream, []byte("ABBB"))
+	binary.LittleEndian.PutUint32(svc, 4)
+	svc[17], svc[18] = 15, 0x33 // unpack version, method
+	for _, c := range utf16.Encode([]rune(":s")) {
+		svc = binary.LittleEndian.AppendUint16(svc, c)
+	}
+	arc := []byte(sig15)
+	arc = append(arc, rar15Block(blockArc, arcSolid, make([]byte, 6), 0)...)
+	arc = append(arc, rar15Packed("a.txt", "ABBB", []byte{0x8b, 0xe5, 0xe6, 0x00})...)
+	arc = append(arc, rar15Block(blockService, blockHasData, svc, 0)...)
+	arc = append(arc, 0x8b, 0xe5, 0xe6, 0x00)
+	arc = append(arc, rar15Packed("b.txt", "ABBB", []byte{0x00, 0x02, 0x00})...)
+	arc = append(arc, rar15Block(blockEnd, 0, nil, 0)...)
+
+	files, err := List("arc.rar", FileSystem(fstest.MapFS{"arc.rar": {Data: arc}}))
+	if err != nil {
+		t.Fatalf("List error = %v", err)
+	}
+	streams, err := files[0].Streams()
+	if err != nil || len(streams) != 1 {
+		t.Fatalf("Streams(a.txt) = %d streams, %v, want 1", len(streams), err)
+	}
+	r, err := streams[0].Open()
+	if err != nil {
+		t.Fatalf("Open(%s) error = %v", streams[0].Name, err)
+	}
+	b, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(b) != "ABBB" {
+		t.Errorf("read stream %s = %q, %v, want %q", streams[0].Name, b, err, "ABBB")
+	}
+	for _, f := range files {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Open(%s) error = %v", f.Name, err)
+		}
+		b, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || string(b) != "ABBB" {
+			t.Errorf("read %s = %q, %v, want %q", f.Name, b, err, "ABBB")
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package rardecode
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	ErrXattrUnsupported = errors.New("rardecode: extended attributes not supported on this platform")
+)
+
+// Stream represents an NTFS alternate data stream of a file in a RAR archive.
+type Stream struct {
+	Name   string // stream name without the leading ':' and ':$DATA' suffix
+	Size   int64  // unpacked size of the stream
+	blocks *fileBlockList
+	vm     *volumeManager
+}
+
+// Open returns an io.ReadCloser that provides access to the Stream's contents.
+func (s *Stream) Open() (io.ReadCloser, error) {
+	return s.vm.openArchiveFile(s.blocks)
+}
+
+// readAll returns the contents of the stream.
+func (s *Stream) readAll() ([]byte, error) {
+	r, err := s.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// streamName returns the name of an NTFS stream from the service data of its block.
+// RAR 3.x stores the name as UTF-16, while RAR 5 uses UTF-8.
+func streamName(ver int, b []byte) string {
+	var name string
+	if ver == archiveVersion15 {
+		u := make([]uint16, len(b)/2)
+		for i := range u {
+			u[i] = binary.LittleEndian.Uint16(b[i*2:])
+		}
+		name = string(utf16.Decode(u))
+	} else {
+		name = string(b)
+	}
+	name = strings.TrimRight(name, "\x00")
+	name = strings.TrimPrefix(name, ":")
+	return strings.TrimSuffix(name, ":$DATA")
+}
+
+// streams returns the NTFS alternate data streams stored in the service
+// blocks following the last block of a file.
+func (vm *volumeManager) streams(blocks *fileBlockList) ([]*Stream, error) {
+	h := blocks.lastBlock()
+	v, err := vm.openBlockOffset(h, h.PackedSize)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Close()
+	var list []*Stream
+	var prev *fileBlockHeader // previous stream block
+	for {
+		sh, err := v.readBlock()
+		if err == ErrMultiVolume || err == errVolumeOrArchiveEnd {
+			if prev == nil || prev.last {
+				return list, nil
+			}
+			// stream continues in the next volume
+			err = v.openNext()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		} else if err == io.EOF {
+			if prev != nil && !prev.last {
+				return nil, ErrUnexpectedArcEnd
+			}
+			return list, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if sh.service == "" {
+			// streams are stored before the next file block
+			return list, nil
+		}
+		if sh.service != serviceStream {
+			continue
+		}
+		// service blocks don't depend on the preceding solid files
+		sh.Solid = false
+		if sh.first {
+			list = append(list, &Stream{
+				Name:   streamName(v.ver, sh.subData),
+				Size:   sh.UnPackedSize,
+				blocks: newFileBlockList(sh),
+				vm:     vm,
+			})
+		} else {
+			if prev == nil || prev.last {
+				return nil, ErrInvalidFileBlock
+			}
+			sh.packedOff = prev.packedOff + prev.PackedSize
+			sh.blocknum = prev.blocknum + 1
+			list[len(list)-1].blocks.addBlock(sh)
+		}
+		prev = sh
+	}
+}
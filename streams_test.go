@@ -0,0 +1,106 @@
+package rardecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"testing"
+	"testing/fstest"
+	"unicode/utf16"
+)
+
+// rar15File returns the header data of a stored RAR 3.x file or service block.
+func rar15File(name string, contents []byte) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len(contents))) // packed size
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(contents)))    // unpacked size
+	b = append(b, 2)                                                  // host os
+	b = binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(contents))
+	b = binary.LittleEndian.AppendUint32(b, 0) // file time
+	b = append(b, 29, 0x30)                    // unpack version, method
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(name)))
+	b = binary.LittleEndian.AppendUint32(b, 0) // attributes
+	return append(b, name...)
+}
+
+func TestStreams(t *testing.T) {
+	zone := []byte("[ZoneTransfer]\r\nZoneId=3\r\n")
+	app := []byte("application stream")
+
+	// RAR 5 STM service blocks store the stream name in the service data record
+	stream50 := func(name string, contents []byte) []byte {
+		rec := append(binary.AppendUvarint(nil, 7), name...)
+		extra := append(binary.AppendUvarint(nil, uint64(len(rec))), rec...)
+		return rar5Block(block5Service, 0, rar5FileData(serviceStream, contents), extra, contents)
+	}
+	arc50 := []byte(sig50)
+	arc50 = append(arc50, rar5Block(block5Arc, 0, []byte{0}, nil, nil)...)
+	arc50 = append(arc50, rar5Block(block5File, 0, rar5FileData("a.txt", []byte("a")), nil, []byte("a"))...)
+	arc50 = append(arc50, stream50(":Zone.Identifier:$DATA", zone)...)
+	arc50 = append(arc50, stream50(":app", app)...)
+	arc50 = append(arc50, rar5Block(block5File, 0, rar5FileData("b.txt", []byte("b")), nil, []byte("b"))...)
+	arc50 = append(arc50, rar5Block(block5End, 0, []byte{0}, nil, nil)...)
+
+	// RAR 3.x STM service blocks store the stream name as UTF-16
+	stream30 := func(name string, contents []byte) []byte {
+		svc := rar15File(serviceStream, contents)
+		for _, c := range utf16.Encode([]rune(name)) {
+			svc = binary.LittleEndian.AppendUint16(svc, c)
+		}
+		return append(rar15Block(blockService, blockHasData, svc, 0), contents...)
+	}
+	arc30 := []byte(sig15)
+	arc30 = append(arc30, rar15Block(blockArc, 0, make([]byte, 6), 0)...)
+	arc30 = append(arc30, rar15Block(blockFile, blockHasData, rar15File("a.txt", []byte("a")), 0)...)
+	arc30 = append(arc30, 'a')
+	arc30 = append(arc30, stream30(":Zone.Identifier:$DATA", zone)...)
+	arc30 = append(arc30, stream30(":app", app)...)
+	arc30 = append(arc30, rar15Block(blockFile, blockHasData, rar15File("b.txt", []byte("b")), 0)...)
+	arc30 = append(arc30, 'b')
+	arc30 = append(arc30, rar15Block(blockEnd, 0, nil, 0)...)
+
+	fsys := fstest.MapFS{
+		"arc50.rar": {Data: arc50},
+		"arc30.rar": {Data: arc30},
+	}
+	for _, name := range []string{"arc50.rar", "arc30.rar"} {
+		files, err := List(name, FileSystem(fsys))
+		if err != nil {
+			t.Fatalf("List(%s) error = %v", name, err)
+		}
+		if len(files) != 2 {
+			t.Fatalf("List(%s) returned %d files, want 2", name, len(files))
+		}
+		streams, err := files[0].Streams()
+		if err != nil {
+			t.Fatalf("Streams(%s) error = %v", name, err)
+		}
+		want := []struct {
+			name     string
+			contents []byte
+		}{
+			{"Zone.Identifier", zone},
+			{"app", app},
+		}
+		if len(streams) != len(want) {
+			t.Fatalf("Streams(%s) returned %d streams, want %d", name, len(streams), len(want))
+		}
+		for i, s := range streams {
+			if s.Name != want[i].name || s.Size != int64(len(want[i].contents)) {
+				t.Errorf("%s stream %d = %q (%d bytes), want %q (%d bytes)", name, i, s.Name, s.Size, want[i].name, len(want[i].contents))
+			}
+			r, err := s.Open()
+			if err != nil {
+				t.Fatalf("Open(%s) error = %v", s.Name, err)
+			}
+			b, err := io.ReadAll(r)
+			r.Close()
+			if err != nil || !bytes.Equal(b, want[i].contents) {
+				t.Errorf("%s stream %q = %q, %v, want %q", name, s.Name, b, err, want[i].contents)
+			}
+		}
+		if streams, err = files[1].Streams(); err != nil || len(streams) != 0 {
+			t.Errorf("Streams(%s) = %d streams, %v, want none", files[1].Name, len(streams), err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package rardecode
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -32,15 +33,21 @@ func (fs osFS) Open(name string) (fs.File, error) {
 }
 
 type options struct {
-	bsize                 int     // size to be use for bufio.Reader
-	maxDictSize           int64   // max dictionary size
-	fs                    fs.FS   // filesystem to use to open files
-	pass                  *string // password for encrypted volumes
-	skipCheck             bool
-	openCheck             bool
-	parallelRead          bool // enable parallel reading for multi-volume archives
-	maxConcurrentVolumes  int  // max concurrent volumes to process (default: 10)
-	maxVolumes            int  // max number of volumes to discover (default: 10000)
+	bsize                int     // size to be use for bufio.Reader
+	maxDictSize          int64   // max dictionary size
+	fs                   fs.FS   // filesystem to use to open files
+	pass                 *string // password for encrypted volumes
+	skipCheck            bool
+	openCheck            bool
+	parallelRead         bool  // enable parallel reading for multi-volume archives
+	maxConcurrentVolumes int   // max concurrent volumes to process (default: 10)
+	maxVolumes           int   // max number of volumes to discover (default: 10000)
+	revVolumes           bool  // rebuild missing volumes from recovery volumes
+	securityDescriptors  bool  // read NTFS security descriptors from ACL blocks
+	volSize              int64 // maximum size of volumes written by a Writer
+	blake2               bool  // Writer stores BLAKE2sp file hashes
+	encHeaders           bool  // Writer encrypts block headers
+	noMAC                bool  // Writer stores plain checksums of encrypted files
 }
 
 // An Option is used for optional archive extraction settings.
@@ -64,7 +71,8 @@ func FileSystem(fs fs.FS) Option {
 	return func(o *options) { o.fs = fs }
 }
 
-// Password sets the password to use for decrypting archives.
+// Password sets the password to use for decrypting archives, or for encrypting
+// archives written by a Writer.
 func Password(pass string) Option {
 	return func(o *options) { o.pass = &pass }
 }
@@ -75,6 +83,17 @@ func SkipCheck(o *options) { o.skipCheck = true }
 // OpenFSCheck flags the archive files to be checked on Open or List.
 func OpenFSCheck(o *options) { o.openCheck = true }
 
+// RecoveryVolumes enables rebuilding missing volumes of a multi-volume archive
+// from RAR 3.x or RAR 5 .rev recovery volumes while reading the archive.
+// Rebuilt volumes are stored in temporary files.
+func RecoveryVolumes(o *options) { o.revVolumes = true }
+
+// SecurityDescriptors enables reading the NTFS security descriptors stored in
+// ACL service blocks while listing an archive. They are set in the FileHeader of
+// the files returned by List and OpenFS, but not by Reader.Next, as the service
+// blocks follow the file data.
+func SecurityDescriptors(o *options) { o.securityDescriptors = true }
+
 // ParallelRead enables parallel reading of multi-volume archives for improved performance.
 // This option only applies to multi-volume archives; single-volume archives will use sequential reading.
 func ParallelRead(enable bool) Option {
@@ -131,6 +150,8 @@ type readerVolume struct {
 	ver int              // archive file format version
 	arc archiveBlockReader
 	opt *options
+
+	prev *fileBlockHeader // last file block read
 }
 
 func (v *readerVolume) init(r io.Reader, volnum int) error {
@@ -145,6 +166,8 @@ func (v *readerVolume) init(r io.Reader, volnum int) error {
 	}
 	if v.arc == nil {
 		switch v.br.ver {
+		case archiveVersion14:
+			v.arc = newArchive14()
 		case archiveVersion15:
 			v.arc = newArchive15(v.opt.pass)
 		case archiveVersion50:
@@ -167,7 +190,8 @@ func (v *readerVolume) init(r io.Reader, volnum int) error {
 	return nil
 }
 
-func (v *readerVolume) nextBlock() (*fileBlockHeader, error) {
+// readBlock returns the next file or service block in the current volume.
+func (v *readerVolume) readBlock() (*fileBlockHeader, error) {
 	if v.n > 0 {
 		err := v.br.Discard(v.n)
 		if err != nil {
@@ -181,33 +205,36 @@ func (v *readerVolume) nextBlock() (*fileBlockHeader, error) {
 	}
 	f.volnum = v.num
 	f.dataOff = v.br.off
-	v.n = f.PackedSize
+	if f.packed == nil {
+		// packed data stored in the block header has already been read
+		v.n = f.PackedSize
+	}
 	return f, nil
 }
 
-// nextBlockHeaderOnly reads the next block header without discarding the current block's data.
-// This is used for metadata-only iteration to skip large files efficiently.
-// The caller must manually skip the accumulated packed data after reading all headers.
-func (v *readerVolume) nextBlockHeaderOnly() (*fileBlockHeader, error) {
-	// We still need to discard the data to position the reader correctly
-	// for reading the next header, but this will be optimized later by
-	// doing a single large skip for all blocks at once
-	if v.n > 0 {
-		err := v.br.Discard(v.n)
+// nextBlock returns the next file block in the current volume, skipping any service blocks.
+// File metadata stored in service blocks is added to the preceding file block.
+func (v *readerVolume) nextBlock() (*fileBlockHeader, error) {
+	for {
+		f, err := v.readBlock()
 		if err != nil {
 			return nil, err
 		}
-		v.n = 0
+		if f.service == "" {
+			v.prev = f
+			return f, nil
+		}
+		if v.prev != nil {
+			v.readFileService(v.prev, f)
+		}
 	}
+}
 
-	f, err := v.arc.nextBlock(v.br)
-	if err != nil {
-		return nil, err
-	}
-	f.volnum = v.num
-	f.dataOff = v.br.off
-	v.n = f.PackedSize
-	return f, nil
+// nextBlockHeaderOnly returns the next file block header for metadata-only iteration.
+// It is the same as nextBlock, which discards the current block's data to position
+// the reader at the next header.
+func (v *readerVolume) nextBlockHeaderOnly() (*fileBlockHeader, error) {
+	return v.nextBlock()
 }
 
 func (v *readerVolume) Read(p []byte) (int, error) {
@@ -468,6 +495,14 @@ type volumeManager struct {
 	mu    sync.Mutex
 	files []string // file names for each volume
 	old   bool     // uses old naming scheme
+
+	hdr *ArchiveHeader // main archive header of the first volume
+
+	revMu   sync.Mutex       // held while rebuilding volumes
+	revs    *revSet          // recovery volumes, nil if not yet read
+	rebuilt map[int]*os.File // temporary files of volumes rebuilt from recovery volumes
+
+	solid *solidCache // solid files of the archive, nil if the files haven't been listed
 }
 
 func (vm *volumeManager) Files() []string {
@@ -476,6 +511,15 @@ func (vm *volumeManager) Files() []string {
 	return vm.files
 }
 
+// archiveHeader returns a copy of the main archive header of the first volume.
+func (vm *volumeManager) archiveHeader() *ArchiveHeader {
+	if vm.hdr == nil {
+		return nil
+	}
+	h := *vm.hdr
+	return &h
+}
+
 // GetVolumePath returns the full path to a volume file by volume number.
 func (vm *volumeManager) GetVolumePath(volnum int) string {
 	vm.mu.Lock()
@@ -505,8 +549,19 @@ func (vm *volumeManager) tryNewName(file string) (fs.File, error) {
 	return nil, err
 }
 
-// next opens the next volume file in the archive.
+// openVolumeFile opens the volume file volnum. If the file doesn't exist and
+// RecoveryVolumes is set, the volume is rebuilt from the recovery volumes.
 func (vm *volumeManager) openVolumeFile(volnum int) (fs.File, error) {
+	f, err := vm.openVolumeName(volnum)
+	if err != nil && vm.opt.revVolumes && errors.Is(err, fs.ErrNotExist) {
+		return vm.openRebuiltVolume(volnum, err)
+	}
+	return f, err
+}
+
+// openVolumeName opens the volume file volnum, working out its name from the
+// previous volume names if needed.
+func (vm *volumeManager) openVolumeName(volnum int) (fs.File, error) {
 	vm.mu.Lock()
 	defer vm.mu.Unlock()
 
@@ -570,25 +625,85 @@ func (vm *volumeManager) openBlockOffset(h *fileBlockHeader, offset int64) (*fil
 	return v, nil
 }
 
-func (vm *volumeManager) openArchiveFile(blocks *fileBlockList) (fs.File, error) {
+// comment returns the archive comment stored in the first volume.
+// An empty string is returned if the archive has no comment.
+func (vm *volumeManager) comment() (string, error) {
+	v, err := vm.newVolume(0)
+	if err != nil {
+		return "", err
+	}
+	defer v.Close()
+	for {
+		h, err := v.readBlock()
+		if err == io.EOF || err == errVolumeOrArchiveEnd || err == ErrMultiVolume {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+		if h.service == "" {
+			// comments are always stored before the first file block
+			return "", nil
+		}
+		if h.service != serviceComment {
+			continue
+		}
+		pr := &packedFileReader{v: v, opt: vm.opt}
+		var r archiveFile = pr
+		if h.packed != nil {
+			r = newBytesFile(pr, h.packed)
+		}
+		h.Solid = false
+		f, err := pr.newArchiveFileFrom(r, newFileBlockList(h))
+		if err != nil {
+			return "", err
+		}
+		b, err := io.ReadAll(f)
+		if err != nil {
+			return "", err
+		}
+		return string(bytes.TrimRight(b, "\x00")), nil
+	}
+}
+
+// openFileReader opens the file in blocks for reading from the start of its contents.
+func (vm *volumeManager) openFileReader(blocks *fileBlockList) (archiveFile, io.Closer, error) {
 	h := blocks.firstBlock()
+	if h.decVer > 0 && (h.Solid || h.arcSolid) && vm.solid != nil {
+		// files in solid archives are decoded using the cached solid decoder,
+		// other blocks such as alternate data streams are decoded on their own
+		if _, ok := vm.solid.files[blocks]; ok {
+			return vm.openSolidFile(blocks)
+		}
+	}
 	if h.Solid {
-		return nil, ErrSolidOpen
+		return nil, nil, ErrSolidOpen
 	}
 	v, err := vm.openBlockOffset(h, 0)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	pr := newPackedFileReader(v, vm.opt)
 	f, err := pr.newArchiveFile(blocks)
 	if err != nil {
 		v.Close()
+		return nil, nil, err
+	}
+	return f, v, nil
+}
+
+func (vm *volumeManager) openArchiveFile(blocks *fileBlockList) (fs.File, error) {
+	f, v, err := vm.openFileReader(blocks)
+	if err != nil {
 		return nil, err
 	}
 	if sr, ok := f.(archiveFileSeeker); ok {
-		return &fileSeekCloser{archiveFileSeeker: sr, Closer: v}, nil
+		return &fileSeekCloser{archiveFileSeeker: sr, Closer: v, vm: vm, blocks: blocks}, nil
+	}
+	if _, ok := f.(*errorFile); ok {
+		return &fileCloser{archiveFile: f, Closer: v}, nil
 	}
-	return &fileCloser{archiveFile: f, Closer: v}, nil
+	// compressed and encrypted files are seeked by decoding the file again
+	return newSeekFile(vm, blocks, f, v), nil
 }
 
 func openVolume(filename string, opts *options) (*fileVolume, error) {
@@ -603,5 +718,6 @@ func openVolume(filename string, opts *options) (*fileVolume, error) {
 		return nil, err
 	}
 	vm.old = v.arc.useOldNaming()
+	vm.hdr = v.arc.archiveHeader()
 	return v, nil
 }
@@ -20,6 +20,7 @@ type parallelVolumeReader struct {
 	opt             *options
 	maxConcurrent   int
 	volumeCount     int
+	missingErr      error // error opening the volume after the last one found
 	headersByVolume map[int][]*fileBlockHeader
 	mu              sync.RWMutex
 }
@@ -54,14 +55,16 @@ func (pvr *parallelVolumeReader) discoverVolumeCount() int {
 		if count >= pvr.opt.maxVolumes { // safety limit
 			break
 		}
-		_, err := pvr.vm.openVolumeFile(count)
+		f, err := pvr.vm.openVolumeFile(count)
 		if err != nil {
 			if errors.Is(err, fs.ErrNotExist) {
+				pvr.missingErr = err
 				break
 			}
 			// Other errors - cannot determine count reliably
 			return -1
 		}
+		f.Close()
 		count++
 	}
 	return count
@@ -96,6 +99,10 @@ func (pvr *parallelVolumeReader) readVolumeHeaders(c ctx.Context, volnum int) ([
 				break
 			}
 			if err == ErrMultiVolume {
+				if volnum == pvr.volumeCount-1 && pvr.missingErr != nil {
+					// the archive continues in a volume that doesn't exist
+					return nil, pvr.missingErr
+				}
 				// File continues in next volume - this is expected
 				// We still want to return the headers we've collected so far
 				break
@@ -228,33 +235,30 @@ func (pvr *parallelVolumeReader) assembleFileBlocks() []*fileBlockList {
 	pvr.mu.RLock()
 	defer pvr.mu.RUnlock()
 
-	// Map to track files by name
-	fileMap := make(map[string]*fileBlockList)
-	fileOrder := []string{} // Track insertion order
+	// Map to track the last file started with each name and version, as
+	// archives may store several versions of a file, or the same version twice
+	type fileKey struct {
+		name    string
+		version int
+	}
+	fileMap := make(map[fileKey]*fileBlockList)
+	result := []*fileBlockList{} // files in archive order
 
 	// Process volumes in order
 	for volnum := 0; volnum < pvr.volumeCount; volnum++ {
 		headers := pvr.headersByVolume[volnum]
 
 		for _, h := range headers {
-			fileName := h.Name
+			key := fileKey{h.Name, h.Version}
 
 			if h.first {
 				// First block of a new file
-				if _, exists := fileMap[fileName]; !exists {
-					fileMap[fileName] = newFileBlockList(h)
-					fileOrder = append(fileOrder, fileName)
-				} else {
-					// This is a new version of an existing file
-					// Keep the one with higher version
-					existing := fileMap[fileName].firstBlock()
-					if h.Version > existing.Version {
-						fileMap[fileName] = newFileBlockList(h)
-					}
-				}
+				blocks := newFileBlockList(h)
+				fileMap[key] = blocks
+				result = append(result, blocks)
 			} else {
 				// Continuation block
-				if blocks, exists := fileMap[fileName]; exists {
+				if blocks, exists := fileMap[key]; exists {
 					// Add to existing file
 					h.blocknum = len(blocks.blocks)
 					blocks.addBlock(h)
@@ -265,10 +269,8 @@ func (pvr *parallelVolumeReader) assembleFileBlocks() []*fileBlockList {
 		}
 	}
 
-	// Convert to ordered slice
-	result := make([]*fileBlockList, 0, len(fileOrder))
-	for _, fileName := range fileOrder {
-		result = append(result, fileMap[fileName])
+	for _, blocks := range result {
+		blocks.copyServiceData(blocks.lastBlock())
 	}
 
 	return result
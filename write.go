@@ -0,0 +1,813 @@
+package rardecode
+
+import (
+	"crypto/aes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	sig50         = sigPrefix + "\x01\x00" // RAR 5 archive signature
+	minVolumeSize = 1024                   // minimum size of a volume written by a Writer
+	endBlockSize  = 8                      // size of a RAR 5 end of archive block
+)
+
+var (
+	ErrWriterClosed       = errors.New("rardecode: write to closed archive")
+	ErrWriteSize          = errors.New("rardecode: file data doesn't match file size")
+	ErrWriteUnknownSize   = errors.New("rardecode: file size must be known to write a file")
+	ErrWriteBadName       = errors.New("rardecode: invalid file name")
+	ErrVolumeSizeTooSmall = errors.New("rardecode: volume size too small")
+	ErrWriteNoPassword    = errors.New("rardecode: header encryption requires a password")
+	ErrCopyUnsupported    = errors.New("rardecode: file can't be copied without decoding")
+	ErrCopyPassword       = errors.New("rardecode: copied file isn't encrypted with the Writer's password")
+)
+
+// VolumeSize sets the maximum size of each volume written by a Writer created
+// with CreateWriter, splitting the archive into multiple volumes.
+func VolumeSize(size int64) Option {
+	return func(o *options) { o.volSize = size }
+}
+
+// UseBLAKE2sp makes a Writer store BLAKE2sp file hashes instead of CRC32 checksums.
+func UseBLAKE2sp(o *options) { o.blake2 = true }
+
+// EncryptHeaders makes a Writer encrypt all block headers, hiding the file names
+// and other file information. It requires the Password option.
+func EncryptHeaders(o *options) { o.encHeaders = true }
+
+// MACChecksums sets whether a Writer stores the checksums of encrypted files as
+// MACs keyed with the password, so they can't be used to verify guesses of the
+// file contents. It is enabled by default.
+func MACChecksums(enable bool) Option {
+	return func(o *options) { o.noMAC = !enable }
+}
+
+// appendBlock50 appends a RAR 5 block header to b.
+func appendBlock50(b []byte, htype, flags uint64, fields, extra []byte, dataSize int64, hasData bool) []byte {
+	var h []byte
+	h = binary.AppendUvarint(h, htype)
+	if len(extra) > 0 {
+		flags |= block5HasExtra
+	}
+	if hasData {
+		flags |= block5HasData
+	}
+	h = binary.AppendUvarint(h, flags)
+	if len(extra) > 0 {
+		h = binary.AppendUvarint(h, uint64(len(extra)))
+	}
+	if hasData {
+		h = binary.AppendUvarint(h, uint64(dataSize))
+	}
+	h = append(h, fields...)
+	h = append(h, extra...)
+	hdr := binary.AppendUvarint(nil, uint64(len(h)))
+	hdr = append(hdr, h...)
+	b = binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(hdr))
+	return append(b, hdr...)
+}
+
+// appendExtra50 appends a RAR 5 extra record to b.
+func appendExtra50(b []byte, ftype uint64, data []byte) []byte {
+	size := binary.AppendUvarint(nil, ftype)
+	b = binary.AppendUvarint(b, uint64(len(size)+len(data)))
+	b = append(b, size...)
+	return append(b, data...)
+}
+
+// appendTimeRecord50 appends a high precision time record for the times of h to b.
+// Unix time with nanoseconds is used when possible, otherwise Windows FILETIME.
+func appendTimeRecord50(b []byte, h *FileHeader) []byte {
+	times := []time.Time{h.ModificationTime, h.CreationTime, h.AccessTime}
+	var flags uint64
+	unix := true
+	for i, t := range times {
+		if t.IsZero() {
+			continue
+		}
+		flags |= file5ExtraTimeHasMTime << i
+		if sec := t.Unix(); sec < 0 || sec > math.MaxUint32 {
+			unix = false
+		}
+	}
+	if flags == 0 {
+		return b
+	}
+	if unix {
+		flags |= file5ExtraTimeIsUnixTime | file5ExtraTimeHasUnixNS
+	}
+	data := binary.AppendUvarint(nil, flags)
+	for _, t := range times {
+		if t.IsZero() {
+			continue
+		}
+		if unix {
+			data = binary.LittleEndian.AppendUint32(data, uint32(t.Unix()))
+		} else {
+			// 100-nanosecond intervals since January 1, 1601
+			ft := t.Unix()*10000000 + int64(t.Nanosecond()/100) + 116444736000000000
+			data = binary.LittleEndian.AppendUint64(data, uint64(ft))
+		}
+	}
+	if unix {
+		for _, t := range times {
+			if !t.IsZero() {
+				data = binary.LittleEndian.AppendUint32(data, uint32(t.Nanosecond()))
+			}
+		}
+	}
+	return appendExtra50(b, 3, data)
+}
+
+// filePart50 is the header of a part of a file written to a single volume.
+type filePart50 struct {
+	h        *FileHeader
+	flags    uint64 // block flags
+	size     int64  // size of the file data in the part
+	hashType int    // HashCRC32, HashBLAKE2sp or HashNone
+	compInfo uint64 // compression information, 0 for stored files
+	extra    []byte // extra records written before the time and hash records
+}
+
+// header returns the block header of the part, with the part or file checksum sum.
+// A nil sum returns a header of the same size with the checksum set to zero.
+func (p *filePart50) header(sum []byte) []byte {
+	h := p.h
+	var flags uint64
+	var fields []byte
+	if h.IsDir {
+		flags |= file5IsDir
+	}
+	if p.hashType == HashCRC32 {
+		flags |= file5HasCRC32
+	}
+	fields = binary.AppendUvarint(fields, flags)
+	fields = binary.AppendUvarint(fields, uint64(h.UnPackedSize))
+	fields = binary.AppendUvarint(fields, uint64(h.Attributes))
+	if flags&file5HasCRC32 > 0 {
+		if sum == nil {
+			sum = make([]byte, 4)
+		}
+		fields = append(fields, sum...)
+	}
+	fields = binary.AppendUvarint(fields, p.compInfo)
+	if h.HostOS == HostOSWindows {
+		fields = binary.AppendUvarint(fields, 0)
+	} else {
+		fields = binary.AppendUvarint(fields, 1)
+	}
+	fields = binary.AppendUvarint(fields, uint64(len(h.Name)))
+	fields = append(fields, h.Name...)
+
+	extra := appendTimeRecord50(p.extra, h)
+	if p.hashType == HashBLAKE2sp {
+		if sum == nil {
+			sum = make([]byte, blake2sSize)
+		}
+		extra = appendExtra50(extra, 2, append([]byte{hash5Blake2sp}, sum...))
+	}
+	return appendBlock50(nil, block5File, p.flags, fields, extra, p.size, !h.IsDir)
+}
+
+// Writer writes a RAR 5 archive containing stored files. Each file is written by
+// calling CreateHeader followed by writes to the returned io.Writer.
+//
+// If the Password option is set, file contents are encrypted with AES-256 and
+// file checksums are stored as MACs, as WinRAR does. File headers are only
+// encrypted if the EncryptHeaders option is also set.
+type Writer struct {
+	opt      *options
+	keys     *writeKeys     // encryption keys, nil if not encrypting
+	copyKeys *archive50     // derives keys to check the password of copied files
+	w        io.WriteSeeker // current volume
+	cl       io.Closer      // closes the current volume, nil if not owned by the Writer
+	create   func(volnum int) (io.WriteSeeker, io.Closer, error)
+	off      int64 // offset in the current volume
+	volStart int64 // offset of the first file in the current volume
+	volnum   int   // current volume number
+	multi    bool  // archive is split into volumes
+	solid    bool  // archive is solid
+	closed   bool
+	err      error // sticky write error
+
+	// current file
+	part     *filePart50
+	hdrOff   int64              // offset of the current part's header
+	hdrIV    []byte             // iv of the current part's header if headers are encrypted
+	todo     int64              // data left to write for the file
+	packTodo int64              // packed data left to write for the file
+	partTodo int64              // packed data left to write for the current part
+	fileHash hash.Hash          // checksum of the whole file
+	partHash hash.Hash          // checksum of the packed data in the current part
+	fileSum  []byte             // stored checksum of a copied file, replacing fileHash
+	macKey   []byte             // key used to convert checksums to MACs, nil if not used
+	cw       *cipherBlockWriter // encrypts the file contents, nil if not encrypted
+}
+
+func (w *Writer) newHash() hash.Hash {
+	if w.part.hashType == HashBLAKE2sp {
+		return newBlake2sp()
+	}
+	return newLittleEndianCRC32()
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.off += int64(n)
+	return err
+}
+
+// sealHeader encrypts the block header hdr with iv if headers are encrypted.
+func (w *Writer) sealHeader(hdr, iv []byte) []byte {
+	if !w.opt.encHeaders {
+		return hdr
+	}
+	return w.keys.sealHeader(hdr, iv)
+}
+
+// writeHeader writes the block header hdr, encrypting it with a new iv if
+// headers are encrypted. It returns the iv used.
+func (w *Writer) writeHeader(hdr []byte) ([]byte, error) {
+	var iv []byte
+	if w.opt.encHeaders {
+		var err error
+		iv, err = newIV()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return iv, w.write(w.sealHeader(hdr, iv))
+}
+
+// headerSize returns the number of bytes written for a block header of n bytes.
+func (w *Writer) headerSize(n int) int64 {
+	if w.opt.encHeaders {
+		return int64(sealedHeaderSize(n))
+	}
+	return int64(n)
+}
+
+// startVolume writes the signature and main archive block of volume volnum.
+func (w *Writer) startVolume() error {
+	var flags uint64
+	if w.solid {
+		flags |= arc5Solid
+	}
+	if w.multi {
+		flags |= arc5MultiVol
+		if w.volnum > 0 {
+			flags |= arc5VolNum
+		}
+	}
+	fields := binary.AppendUvarint(nil, flags)
+	if flags&arc5VolNum > 0 {
+		fields = binary.AppendUvarint(fields, uint64(w.volnum))
+	}
+	err := w.write([]byte(sig50))
+	if err == nil && w.opt.encHeaders {
+		err = w.write(w.keys.encryptionBlock())
+	}
+	if err == nil {
+		_, err = w.writeHeader(appendBlock50(nil, block5Arc, 0, fields, nil, 0, false))
+	}
+	w.volStart = w.off
+	return err
+}
+
+// endVolume writes the end of archive block and closes the current volume.
+func (w *Writer) endVolume(last bool) error {
+	var flags uint64
+	if !last {
+		flags = endArc5NotLast
+	}
+	_, err := w.writeHeader(appendBlock50(nil, block5End, 0, binary.AppendUvarint(nil, flags), nil, 0, false))
+	if w.cl != nil {
+		if cerr := w.cl.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// nextVolume ends the current volume and starts the next one.
+func (w *Writer) nextVolume() error {
+	err := w.endVolume(false)
+	if err != nil {
+		return err
+	}
+	w.volnum++
+	w.w, w.cl, err = w.create(w.volnum)
+	if err != nil {
+		return err
+	}
+	w.off = 0
+	return w.startVolume()
+}
+
+// space returns the space left in the current volume for a block header and
+// data, or -1 if there is no size limit.
+func (w *Writer) space() int64 {
+	if !w.multi {
+		return -1
+	}
+	return w.opt.volSize - w.off - w.headerSize(endBlockSize)
+}
+
+// startPart writes the header of the next part of the current file, starting
+// a new volume if there isn't room for the header and some of the file data.
+func (w *Writer) startPart(flags uint64) error {
+	p := *w.part
+	p.flags = flags
+	p.size = w.packTodo
+	hlen := w.headerSize(len(p.header(nil)))
+	if space := w.space(); space >= 0 {
+		if space < hlen+min(w.packTodo, 1) {
+			if w.off == w.volStart {
+				return ErrVolumeSizeTooSmall
+			}
+			err := w.nextVolume()
+			if err != nil {
+				return err
+			}
+			return w.startPart(flags)
+		}
+		if w.packTodo > space-hlen {
+			p.size = space - hlen
+			p.flags |= block5DataNotLast
+		}
+	}
+	w.part = &p
+	w.hdrOff = w.off
+	w.partTodo = p.size
+	w.partHash = w.newHash()
+	var err error
+	w.hdrIV, err = w.writeHeader(p.header(nil))
+	return err
+}
+
+// finishPart rewrites the header of the current part with its checksum.
+func (w *Writer) finishPart() error {
+	p := w.part
+	if p.hashType == HashNone {
+		return nil
+	}
+	var sum []byte
+	if p.flags&block5DataNotLast > 0 {
+		sum = w.partHash.Sum(nil)
+	} else if w.fileSum != nil {
+		sum = w.fileSum
+	} else {
+		sum = w.fileHash.Sum(nil)
+	}
+	if w.macKey != nil && (w.fileSum == nil || p.flags&block5DataNotLast > 0) {
+		sum = macSum(w.macKey, sum)
+	}
+	_, err := w.w.Seek(w.hdrOff, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(w.sealHeader(p.header(sum), w.hdrIV))
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Seek(w.off, io.SeekStart)
+	return err
+}
+
+// finishFile completes the current file.
+func (w *Writer) finishFile() error {
+	if w.part == nil {
+		return nil
+	}
+	if w.todo > 0 || w.packTodo > 0 {
+		return ErrWriteSize
+	}
+	err := w.finishPart()
+	w.part = nil
+	return err
+}
+
+// writeData writes data to the current file.
+func (w *Writer) writeData(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.part == nil {
+		return 0, ErrWriterClosed
+	}
+	if int64(len(p)) > w.todo {
+		return 0, ErrWriteSize
+	}
+	w.fileHash.Write(p)
+	w.todo -= int64(len(p))
+	if w.cw == nil {
+		return w.writePacked(p)
+	}
+	_, err := w.writePacked(w.cw.encrypt(p, w.todo == 0))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writePacked writes the packed data of the current file, splitting it
+// into parts across volumes as needed.
+func (w *Writer) writePacked(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		if w.partTodo == 0 {
+			err := w.finishPart()
+			if err == nil {
+				err = w.nextVolume()
+			}
+			if err == nil {
+				err = w.startPart(block5DataNotFirst)
+			}
+			if err != nil {
+				w.err = err
+				return n, err
+			}
+		}
+		b := p[:min(int64(len(p)), w.partTodo)]
+		err := w.write(b)
+		if err != nil {
+			w.err = err
+			return n, err
+		}
+		w.partHash.Write(b)
+		w.partTodo -= int64(len(b))
+		w.packTodo -= int64(len(b))
+		n += len(b)
+		p = p[len(b):]
+	}
+	return n, nil
+}
+
+type fileWriter struct{ w *Writer }
+
+func (fw fileWriter) Write(p []byte) (int, error) { return fw.w.writeData(p) }
+
+// CreateHeader adds a file or directory to the archive using h for its name,
+// size, attributes and times, and returns an io.Writer for writing the file's
+// contents. Exactly h.UnPackedSize bytes must be written before the next call
+// to CreateHeader or Close. Only Name, IsDir, HostOS, Attributes, UnPackedSize
+// and the times are used from h. Names containing a ".." element or starting
+// with a drive letter return ErrWriteBadName.
+func (w *Writer) CreateHeader(h *FileHeader) (io.Writer, error) {
+	fh, err := w.beginFile(h)
+	if err != nil {
+		return nil, err
+	}
+	if fh.Attributes == 0 && fh.HostOS != HostOSWindows {
+		// default unix file mode
+		if fh.IsDir {
+			fh.Attributes = 0o40755
+		} else {
+			fh.Attributes = 0o100644
+		}
+	}
+	part := &filePart50{h: fh, hashType: HashCRC32}
+	if fh.IsDir {
+		part.hashType = HashNone
+	} else if w.opt.blake2 {
+		part.hashType = HashBLAKE2sp
+	}
+	w.todo = fh.UnPackedSize
+	packSize := fh.UnPackedSize
+	if w.keys != nil && !fh.IsDir {
+		iv, err := newIV()
+		if err != nil {
+			return nil, err
+		}
+		part.extra = w.keys.fileEncryptionRecord(nil, iv, !w.opt.noMAC)
+		w.cw = newCipherBlockWriter(w.keys.block, iv)
+		if !w.opt.noMAC {
+			w.macKey = w.keys.keys[1]
+		}
+		packSize = encryptedSize(fh.UnPackedSize)
+	}
+	err = w.startFile(part, packSize)
+	if err != nil {
+		return nil, err
+	}
+	return fileWriter{w}, nil
+}
+
+type packedWriter struct{ w *Writer }
+
+func (pw packedWriter) Write(p []byte) (int, error) { return pw.w.writePacked(p) }
+
+// Copy adds the file f, returned by List, to the archive by copying its packed
+// data from the source archive without decoding it. The file's header is written
+// with the same name, attributes, times and checksum, and the same RAR 5 extra
+// records, such as the encryption record, so encrypted files are copied without
+// decrypting them. Only stored files and RAR 5 compressed files that don't
+// have the Solid flag set can be copied, other files return ErrCopyUnsupported.
+// Service data stored after the file, such as NTFS security descriptors, isn't copied.
+//
+// Copy doesn't encrypt or decrypt files, so a Writer with a password returns
+// ErrCopyUnsupported for files that aren't encrypted, and encrypted files return
+// ErrCopyPassword unless the Writer has the same password as the source archive.
+// Encrypted files with MAC checksums also need the password of the source archive
+// to be listed to be written by a Writer with a VolumeSize, as the checksum of
+// each part of a split file is a MAC.
+func (w *Writer) Copy(f *File) error {
+	if f.IsDir {
+		return w.copyFile(f.vm, f.blocks, false)
+	}
+	if w.keys != nil && !f.Encrypted {
+		return ErrCopyUnsupported
+	}
+	if rec := encryptionRecord(f.blocks.firstBlock()); rec != nil && !w.copyKeyMatches(rec) {
+		return ErrCopyPassword
+	}
+	return w.copyFile(f.vm, f.blocks, false)
+}
+
+// copyKeyMatches returns if the RAR 5 file encryption record rec of a copied
+// file was made with the Writer's password.
+func (w *Writer) copyKeyMatches(rec []byte) bool {
+	if w.keys == nil {
+		return false
+	}
+	b := readBuf(rec)
+	b.uvarint() // version
+	flags := b.uvarint()
+	if flags&file5EncCheckPresent == 0 || len(b) < 1+saltSize50+aes.BlockSize+12 {
+		// the password can't be checked
+		return false
+	}
+	kdfCount := int(b.byte())
+	salt := b.bytes(saltSize50)
+	b.bytes(aes.BlockSize) // iv
+	check := b.bytes(12)
+	if w.copyKeys == nil {
+		w.copyKeys = newArchive50(w.opt.pass)
+	}
+	_, err := w.copyKeys.getKeys(kdfCount, salt, check)
+	return err == nil
+}
+
+// copyFile adds the file in blocks to the archive by copying its packed data.
+// Compressed files with the Solid flag set are only copied if solid is set.
+func (w *Writer) copyFile(vm *volumeManager, blocks *fileBlockList, solid bool) error {
+	blocks.mu.RLock()
+	list := blocks.blocks
+	blocks.mu.RUnlock()
+	h := list[0]
+	if !list[len(list)-1].last {
+		return ErrUnexpectedArcEnd
+	}
+	switch {
+	case h.Encrypted && encryptionRecord(h) == nil:
+		// only RAR 5 encrypted files have an encryption record with the key parameters
+		return ErrCopyUnsupported
+	case h.decVer != 0 && ((h.Solid && !solid) || (h.decVer != decode50Ver && h.decVer != decode70Ver)):
+		return ErrCopyUnsupported
+	case w.multi && usesMac(h) && list[len(list)-1].hashKey == nil:
+		// the MAC of each part of a split file can't be made without the password
+		return ErrCopyUnsupported
+	}
+	fh, err := w.beginFile(&h.FileHeader)
+	if err != nil {
+		return err
+	}
+	if fh.HostOS == HostOSMSDOS || fh.HostOS == HostOSOS2 {
+		fh.HostOS = HostOSWindows
+	}
+	part := &filePart50{h: fh, hashType: h.HashType, compInfo: h.compInfo}
+	if part.hashType == HashRAR14 || h.Hash == nil {
+		part.hashType = HashNone
+	}
+	for _, e := range h.ExtraRecords {
+		// time and BLAKE2sp hash records are written from fh
+		if e.Type != 3 && (e.Type != 2 || part.hashType != HashBLAKE2sp) {
+			part.extra = appendExtra50(part.extra, e.Type, e.Data)
+		}
+	}
+	w.fileSum = h.Hash
+	w.macKey = list[len(list)-1].hashKey
+	var packSize int64
+	for _, b := range list {
+		packSize += b.PackedSize
+	}
+	err = w.startFile(part, packSize)
+	if err != nil {
+		return err
+	}
+	for _, b := range list {
+		v, err := vm.openBlockOffset(b, 0)
+		if err != nil {
+			w.err = err
+			return err
+		}
+		_, err = io.CopyN(packedWriter{w}, v, b.PackedSize)
+		v.Close()
+		if err != nil {
+			if err == io.EOF {
+				err = ErrUnexpectedArcEnd
+			}
+			w.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+// encryptionRecord returns the data of the RAR 5 file encryption record of h,
+// or nil if it doesn't have one.
+func encryptionRecord(h *fileBlockHeader) []byte {
+	for _, e := range h.ExtraRecords {
+		if e.Type == 1 {
+			return e.Data
+		}
+	}
+	return nil
+}
+
+// usesMac returns if the checksums of the RAR 5 encrypted file h are MACs.
+func usesMac(h *fileBlockHeader) bool {
+	rec := encryptionRecord(h)
+	if rec == nil {
+		return false
+	}
+	b := readBuf(rec)
+	b.uvarint() // version
+	return b.uvarint()&file5EncUseMac > 0
+}
+
+// beginFile finishes the previous file and returns a copy of h with its name
+// normalized, checking the file can be written.
+func (w *Writer) beginFile(h *FileHeader) (*FileHeader, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	if w.closed {
+		return nil, ErrWriterClosed
+	}
+	err := w.finishFile()
+	if err != nil {
+		w.err = err
+		return nil, err
+	}
+	name := strings.TrimPrefix(strings.ReplaceAll(h.Name, "\\", "/"), "/")
+	if name == "" || hasDriveLetter(name) || slices.Contains(strings.Split(name, "/"), "..") {
+		// names must stay inside the directory the archive is extracted to
+		return nil, ErrWriteBadName
+	}
+	if h.UnKnownSize || h.UnPackedSize < 0 {
+		return nil, ErrWriteUnknownSize
+	}
+	fh := *h
+	fh.Name = name
+	if fh.IsDir {
+		fh.UnPackedSize = 0
+	}
+	w.todo, w.fileSum, w.macKey, w.cw = 0, nil, nil, nil
+	return &fh, nil
+}
+
+// startFile writes the header of the first part of the file described by part,
+// which has packSize bytes of packed data.
+func (w *Writer) startFile(part *filePart50, packSize int64) error {
+	w.part = part
+	w.packTodo = packSize
+	w.fileHash = w.newHash()
+	err := w.startPart(0)
+	if err != nil {
+		w.part = nil
+		w.err = err
+	}
+	return err
+}
+
+// Close finishes writing the archive. It doesn't close the io.WriteSeeker
+// passed to NewWriter.
+func (w *Writer) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+	if w.err != nil {
+		if w.cl != nil {
+			w.cl.Close()
+		}
+		return w.err
+	}
+	err := w.finishFile()
+	if err == nil {
+		err = w.endVolume(true)
+	} else if w.cl != nil {
+		w.cl.Close()
+	}
+	if err != nil {
+		w.err = err
+	}
+	return err
+}
+
+// Volumes returns the number of volumes written so far.
+func (w *Writer) Volumes() int {
+	return w.volnum + 1
+}
+
+func newWriter(opt *options) (*Writer, error) {
+	w := &Writer{opt: opt}
+	if opt.pass != nil {
+		var err error
+		w.keys, err = newWriteKeys(*opt.pass)
+		if err != nil {
+			return nil, err
+		}
+	} else if opt.encHeaders {
+		return nil, ErrWriteNoPassword
+	}
+	return w, nil
+}
+
+// NewWriter returns a Writer writing a single volume RAR 5 archive to w.
+// The VolumeSize option is ignored.
+func NewWriter(w io.WriteSeeker, opts ...Option) (*Writer, error) {
+	wr, err := newWriter(getOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	wr.w = w
+	err = wr.startVolume()
+	if err != nil {
+		return nil, err
+	}
+	return wr, nil
+}
+
+// volumeName returns the file name of volume volnum of the archive name
+// written with a volume size, using the name.partNN.rar naming scheme.
+func volumeName(name string, volnum int) string {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	return fmt.Sprintf("%s.part%02d.rar", base, volnum+1)
+}
+
+// VolumeNamer returns the file name of volume volnum of an archive being
+// written, where the first volume is volume 0.
+type VolumeNamer func(volnum int) string
+
+// PartVolumeNamer returns a VolumeNamer that names volumes name.part01.rar,
+// name.part02.rar and so on, with any extension of name removed.
+func PartVolumeNamer(name string) VolumeNamer {
+	return func(volnum int) string { return volumeName(name, volnum) }
+}
+
+// CreateWriter creates the RAR 5 archive file name and returns a Writer for it.
+// If the VolumeSize option is set, the archive is split into volumes of at most
+// that size named name.part01.rar, name.part02.rar and so on, with any extension
+// of name removed.
+func CreateWriter(name string, opts ...Option) (*Writer, error) {
+	opt := getOptions(opts)
+	namer := func(int) string { return name }
+	if opt.volSize > 0 {
+		namer = PartVolumeNamer(name)
+	}
+	return createWriter(namer, opt, false)
+}
+
+// createWriter returns a Writer creating the volume files named by namer,
+// splitting the archive into volumes if opt.volSize is set.
+func createWriter(namer VolumeNamer, opt *options, solid bool) (*Writer, error) {
+	w, err := newWriter(opt)
+	if err != nil {
+		return nil, err
+	}
+	w.multi = w.opt.volSize > 0
+	w.solid = solid
+	if w.multi && w.opt.volSize < minVolumeSize {
+		return nil, ErrVolumeSizeTooSmall
+	}
+	w.create = func(volnum int) (io.WriteSeeker, io.Closer, error) {
+		f, err := os.Create(namer(volnum))
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, f, nil
+	}
+	w.w, w.cl, err = w.create(0)
+	if err != nil {
+		return nil, err
+	}
+	err = w.startVolume()
+	if err != nil {
+		w.cl.Close()
+		return nil, err
+	}
+	return w, nil
+}
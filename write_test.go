@@ -0,0 +1,483 @@
+package rardecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type writeTestFile struct {
+	name     string
+	dir      bool
+	contents []byte
+	mtime    time.Time
+}
+
+func writeTestArchive(t *testing.T, name string, files []writeTestFile, opts ...Option) *Writer {
+	t.Helper()
+	w, err := CreateWriter(name, opts...)
+	if err != nil {
+		t.Fatalf("CreateWriter error = %v", err)
+	}
+	for _, f := range files {
+		fw, err := w.CreateHeader(&FileHeader{
+			Name:             f.name,
+			IsDir:            f.dir,
+			UnPackedSize:     int64(len(f.contents)),
+			ModificationTime: f.mtime,
+		})
+		if err != nil {
+			t.Fatalf("CreateHeader(%s) error = %v", f.name, err)
+		}
+		// write in small pieces to cross volume boundaries mid write
+		for b := f.contents; len(b) > 0; b = b[min(len(b), 700):] {
+			if _, err = fw.Write(b[:min(len(b), 700)]); err != nil {
+				t.Fatalf("Write(%s) error = %v", f.name, err)
+			}
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+	return w
+}
+
+func checkTestArchive(t *testing.T, name string, files []writeTestFile, hashType int, opts ...Option) {
+	t.Helper()
+	r, err := OpenReader(name, opts...)
+	if err != nil {
+		t.Fatalf("OpenReader error = %v", err)
+	}
+	defer r.Close()
+	for _, f := range files {
+		h, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next error = %v, want %s", err, f.name)
+		}
+		if h.Name != f.name || h.IsDir != f.dir || !h.ModificationTime.Equal(f.mtime) {
+			t.Errorf("header = %s, dir %v, mtime %v, want %s, dir %v, mtime %v", h.Name, h.IsDir, h.ModificationTime, f.name, f.dir, f.mtime)
+		}
+		if !f.dir && h.HashType != hashType {
+			t.Errorf("%s hash type = %d, want %d", f.name, h.HashType, hashType)
+		}
+		b, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(b, f.contents) {
+			t.Errorf("read %s = %d bytes, %v, want %d bytes", f.name, len(b), err, len(f.contents))
+		}
+	}
+	if _, err = r.Next(); err != io.EOF {
+		t.Errorf("Next error = %v, want EOF", err)
+	}
+}
+
+// checkPartHashes checks the CRC32 of the packed data of each part of the split
+// files in the named archive, converted to a MAC for encrypted files.
+func checkPartHashes(t *testing.T, name string, opts ...Option) {
+	t.Helper()
+	vm, files, err := listFileBlocks(name, opts)
+	if err != nil {
+		t.Fatalf("listFileBlocks error = %v", err)
+	}
+	var parts int
+	for _, blocks := range files {
+		key := blocks.blocks[0].hashKey // only set in the first and last blocks
+		for _, b := range blocks.blocks {
+			if b.last || b.HashType != HashCRC32 {
+				continue
+			}
+			v, err := vm.openBlockOffset(b, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := crc32.NewIEEE()
+			_, err = io.CopyN(h, v, b.PackedSize)
+			v.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sum := binary.LittleEndian.AppendUint32(nil, h.Sum32())
+			if key != nil {
+				sum = macSum(key, sum)
+			}
+			if !bytes.Equal(b.sum, sum) {
+				t.Errorf("%s part %d checksum = %x, want %x", b.Name, b.blocknum, b.sum, sum)
+			}
+			parts++
+		}
+	}
+	if parts == 0 {
+		t.Errorf("%s has no split files", name)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	big := make([]byte, 5000)
+	for i := range big {
+		big[i] = byte(i * 7)
+	}
+	mtime := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	files := []writeTestFile{
+		{name: "docs", dir: true, mtime: mtime},
+		{name: "docs/a.txt", contents: []byte("hello, world"), mtime: mtime},
+		{name: "empty.txt", mtime: mtime},
+		{name: "big.bin", contents: big, mtime: time.Date(2200, 1, 2, 3, 4, 5, 600, time.UTC)},
+	}
+	dir := t.TempDir()
+
+	name := filepath.Join(dir, "single.rar")
+	writeTestArchive(t, name, files)
+	checkTestArchive(t, name, files, HashCRC32)
+
+	name = filepath.Join(dir, "blake.rar")
+	writeTestArchive(t, name, files, UseBLAKE2sp)
+	checkTestArchive(t, name, files, HashBLAKE2sp)
+
+	name = filepath.Join(dir, "multi.rar")
+	w := writeTestArchive(t, name, files, VolumeSize(1024))
+	if w.Volumes() < 5 {
+		t.Errorf("wrote %d volumes, want at least 5", w.Volumes())
+	}
+	for i := 0; i < w.Volumes(); i++ {
+		fi, err := os.Stat(volumeName(name, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() > 1024 {
+			t.Errorf("volume %d size = %d, want at most 1024", i, fi.Size())
+		}
+	}
+	checkTestArchive(t, volumeName(name, 0), files, HashCRC32)
+
+	w, err := CreateWriter(filepath.Join(dir, "short.rar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw, _ := w.CreateHeader(&FileHeader{Name: "a", UnPackedSize: 2})
+	fw.Write([]byte("a"))
+	if err = w.Close(); err != ErrWriteSize {
+		t.Errorf("Close after short write error = %v, want %v", err, ErrWriteSize)
+	}
+
+	w, err = CreateWriter(filepath.Join(dir, "names.rar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"", "/", "../a", `dir\..\a`, "dir/..", "C:a", `c:\a`} {
+		if _, err = w.CreateHeader(&FileHeader{Name: name}); err != ErrWriteBadName {
+			t.Errorf("CreateHeader(%q) error = %v, want %v", name, err, ErrWriteBadName)
+		}
+	}
+	for _, name := range []string{"a..b", "dir/..a", "file:1"} {
+		if _, err = w.CreateHeader(&FileHeader{Name: name}); err != nil {
+			t.Errorf("CreateHeader(%q) error = %v", name, err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Errorf("Close error = %v", err)
+	}
+}
+
+func TestEncryptedWriter(t *testing.T) {
+	big := make([]byte, 3001)
+	for i := range big {
+		big[i] = byte(i * 13)
+	}
+	mtime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	files := []writeTestFile{
+		{name: "dir", dir: true, mtime: mtime},
+		{name: "dir/a.txt", contents: []byte("sixteen bytes!!!"), mtime: mtime},
+		{name: "empty", mtime: mtime},
+		{name: "big.bin", contents: big, mtime: mtime},
+	}
+	dir := t.TempDir()
+	pass := Password("secret")
+
+	name := filepath.Join(dir, "files.rar")
+	writeTestArchive(t, name, files, pass)
+	checkTestArchive(t, name, files, HashCRC32, pass)
+	list, err := ListArchiveInfo(name, pass)
+	if err != nil {
+		t.Fatalf("ListArchiveInfo error = %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("ListArchiveInfo returned %d files, want 2", len(list))
+	}
+	for _, fi := range list {
+		part := fi.Parts[0]
+		if !fi.AnyEncrypted || part.KdfIterations != 1<<writeKdfCount {
+			t.Errorf("%s encrypted %v, kdf iterations %d", fi.Name, fi.AnyEncrypted, part.KdfIterations)
+		}
+		key := calcKeys50([]byte("secret"), part.Salt, part.KdfIterations)[0]
+		if !bytes.Equal(part.AesKey, key) || fi.TotalPackedSize%16 != 0 {
+			t.Errorf("%s key %x, packed size %d", fi.Name, part.AesKey, fi.TotalPackedSize)
+		}
+	}
+	// an incorrect password is reported when reading an encrypted file
+	r, err := OpenReader(name, Password("wrong"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for err == nil {
+		if _, err = r.Next(); err == nil {
+			_, err = io.ReadAll(r)
+		}
+	}
+	r.Close()
+	if err != ErrBadPassword {
+		t.Errorf("read with wrong password error = %v, want %v", err, ErrBadPassword)
+	}
+
+	name = filepath.Join(dir, "headers.rar")
+	w := writeTestArchive(t, name, files, pass, EncryptHeaders, UseBLAKE2sp, VolumeSize(1024))
+	if w.Volumes() < 3 {
+		t.Errorf("wrote %d volumes, want at least 3", w.Volumes())
+	}
+	name = volumeName(name, 0)
+	checkTestArchive(t, name, files, HashBLAKE2sp, pass)
+	if _, err = ListArchiveInfo(name); err != ErrArchiveEncrypted {
+		t.Errorf("ListArchiveInfo without password error = %v, want %v", err, ErrArchiveEncrypted)
+	}
+	list, err = ListArchiveInfo(name, pass)
+	if err != nil || len(list) != 2 || list[1].Name != "big.bin" || len(list[1].Parts) < 2 {
+		t.Errorf("ListArchiveInfo = %+v, %v", list, err)
+	}
+
+	if _, err = CreateWriter(filepath.Join(dir, "nopass.rar"), EncryptHeaders); err != ErrWriteNoPassword {
+		t.Errorf("CreateWriter error = %v, want %v", err, ErrWriteNoPassword)
+	}
+
+	// checksums are only converted to MACs if MACChecksums is enabled
+	for _, mac := range []bool{true, false} {
+		name = filepath.Join(dir, "mac.rar")
+		writeTestArchive(t, name, files, pass, MACChecksums(mac))
+		checkTestArchive(t, name, files, HashCRC32, pass)
+		fl, err := List(name, pass)
+		if err != nil {
+			t.Fatalf("List error = %v", err)
+		}
+		plain := binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(files[1].contents))
+		if h := fl[1].FileHeader; bytes.Equal(h.Hash, plain) == mac {
+			t.Errorf("MACChecksums(%v): %s checksum = %x, plain checksum %x", mac, h.Name, h.Hash, plain)
+		}
+	}
+}
+
+func TestWriteKeys(t *testing.T) {
+	unhex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	// PBKDF2-HMAC-SHA256 test vector from RFC 7914
+	key := calcKeys50([]byte("passwd"), []byte("salt"), 1)[0]
+	if want := unhex("55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"); !bytes.Equal(key, want) {
+		t.Errorf("calcKeys50 key = %x, want %x", key, want)
+	}
+
+	// The expected values were computed independently of this package with
+	// Python's hashlib.pbkdf2_hmac and openssl. There are no WinRAR archives
+	// with a fixed salt and IV to compare with.
+	salt := unhex("000102030405060708090a0b0c0d0e0f")
+	iv := unhex("101112131415161718191a1b1c1d1e1f")
+	k, err := newWriteKeysSalt("password", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{"key", k.keys[0], "345bf51b5fe7f1716cfac8af9de4f57d7ceba914be4ebc8a6afbfe88678a10e8"},
+		{"hash key", k.keys[1], "c9399711eeb4f7ca765fe95e8dfdb3cd89deb389b62cd0bc0bdb338c21da853a"},
+		{"password check", k.keys[2], "04442c9e2f50b54b68c7ecc1"},
+		{"encryption block", k.encryptionBlock(),
+			"6b7e174121040000010f000102030405060708090a0b0c0d0e0f04442c9e2f50b54b68c7ecc1"},
+		{"file encryption record", k.fileEncryptionRecord(nil, iv, true),
+			"300100030f000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f04442c9e2f50b54b68c7ecc1"},
+		{"file encryption record without MAC", k.fileEncryptionRecord(nil, iv, false),
+			"300100010f000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f04442c9e2f50b54b68c7ecc1"},
+		{"sealed end block", k.sealHeader(unhex("19b23a3503050000"), iv),
+			"101112131415161718191a1b1c1d1e1f038deebe0fbf5937f337700233c21a21"},
+		{"CRC32 MAC", macSum(k.keys[1], unhex("78563412")), "1caafdc7"},
+	}
+	for _, tt := range tests {
+		if want := unhex(tt.want); !bytes.Equal(tt.got, want) {
+			t.Errorf("%s = %x, want %x", tt.name, tt.got, want)
+		}
+	}
+}
+
+func TestWriterCopy(t *testing.T) {
+	big := make([]byte, 3000)
+	for i := range big {
+		big[i] = byte(i * 11)
+	}
+	mtime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	files := []writeTestFile{
+		{name: "dir", dir: true, mtime: mtime},
+		{name: "dir/a.txt", contents: []byte("hello"), mtime: mtime},
+		{name: "drop.txt", contents: []byte("dropped"), mtime: mtime},
+		{name: "big.bin", contents: big, mtime: mtime},
+	}
+	kept := []writeTestFile{files[0], files[1], files[3]}
+	dir := t.TempDir()
+	pass := Password("secret")
+
+	copyFiles := func(src, dst string, opts ...Option) {
+		t.Helper()
+		list, err := List(src)
+		if err != nil {
+			t.Fatalf("List error = %v", err)
+		}
+		w, err := CreateWriter(dst, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range list {
+			if f.Name == "drop.txt" {
+				continue
+			}
+			if err = w.Copy(f); err != nil {
+				t.Fatalf("Copy(%s) error = %v", f.Name, err)
+			}
+		}
+		if err = w.Close(); err != nil {
+			t.Fatalf("Close error = %v", err)
+		}
+	}
+
+	// copied parts are split at different offsets to the source archive
+	src := filepath.Join(dir, "src.rar")
+	writeTestArchive(t, src, files, UseBLAKE2sp)
+	dst := filepath.Join(dir, "dst.rar")
+	copyFiles(src, dst, VolumeSize(1024))
+	checkTestArchive(t, volumeName(dst, 0), kept, HashBLAKE2sp)
+
+	// encrypted files are copied without decrypting them
+	src = filepath.Join(dir, "enc.rar")
+	writeTestArchive(t, src, files, pass, VolumeSize(1024))
+	dst = filepath.Join(dir, "encdst.rar")
+	copyFiles(volumeName(src, 0), dst, pass)
+	checkTestArchive(t, dst, kept, HashCRC32, pass)
+
+	// but only to a Writer with the same password
+	list, err := List(volumeName(src, 0))
+	if err != nil {
+		t.Fatalf("List error = %v", err)
+	}
+	for _, opts := range [][]Option{nil, {Password("other")}} {
+		w, err := CreateWriter(filepath.Join(dir, "badpass.rar"), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = w.Copy(list[0]); err != nil {
+			t.Errorf("Copy(%s) error = %v", list[0].Name, err)
+		}
+		if err = w.Copy(list[1]); err != ErrCopyPassword {
+			t.Errorf("Copy(%s) with %d options error = %v, want %v", list[1].Name, len(opts), err, ErrCopyPassword)
+		}
+		w.Close()
+	}
+
+	// MAC checksums of split files need the password of the source archive
+	src = filepath.Join(dir, "mac.rar")
+	writeTestArchive(t, src, files, pass)
+	list, err = List(src)
+	if err != nil {
+		t.Fatalf("List error = %v", err)
+	}
+	w, err := CreateWriter(filepath.Join(dir, "nomac.rar"), VolumeSize(1024), pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Copy(list[3]); err != ErrCopyUnsupported {
+		t.Errorf("Copy(%s) without password error = %v, want %v", list[3].Name, err, ErrCopyUnsupported)
+	}
+	w.Close()
+	list, err = List(src, pass)
+	if err != nil {
+		t.Fatalf("List error = %v", err)
+	}
+	dst = filepath.Join(dir, "macdst.rar")
+	w, err = CreateWriter(dst, VolumeSize(1024), pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range list {
+		if f.Name != "drop.txt" {
+			if err = w.Copy(f); err != nil {
+				t.Fatalf("Copy(%s) error = %v", f.Name, err)
+			}
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkTestArchive(t, volumeName(dst, 0), kept, HashCRC32, pass)
+	checkPartHashes(t, volumeName(dst, 0), pass)
+
+	// files aren't encrypted when copied to a Writer with a password
+	list, err = List(filepath.Join(dir, "src.rar"))
+	if err != nil {
+		t.Fatalf("List error = %v", err)
+	}
+	w, err = CreateWriter(filepath.Join(dir, "plain.rar"), pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Copy(list[0]); err != nil {
+		t.Errorf("Copy(%s) error = %v", list[0].Name, err)
+	}
+	if err = w.Copy(list[1]); err != ErrCopyUnsupported {
+		t.Errorf("Copy(%s) to encrypted archive error = %v, want %v", list[1].Name, err, ErrCopyUnsupported)
+	}
+	w.Close()
+
+	// RAR 3.x stored files can be copied, compressed files can't
+	arc := []byte(sig15)
+	arc = append(arc, rar15Block(blockArc, 0, make([]byte, 6), 0)...)
+	arc = append(arc, rar15Block(blockFile, blockHasData, rar15File("s.txt", []byte("stored")), 0)...)
+	arc = append(arc, "stored"...)
+	arc = append(arc, rar15Packed("c.txt", "ABABABABABABA", []byte{0x77, 0xcb, 0xcd, 0x00, 0x40})...)
+	arc = append(arc, rar15Block(blockEnd, 0, nil, 0)...)
+	list, err = List("arc.rar", FileSystem(fstest.MapFS{"arc.rar": {Data: arc}}))
+	if err != nil || len(list) != 2 {
+		t.Fatalf("List = %d files, %v", len(list), err)
+	}
+	dst = filepath.Join(dir, "rar3.rar")
+	w, err = CreateWriter(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Copy(list[0]); err != nil {
+		t.Errorf("Copy(%s) error = %v", list[0].Name, err)
+	}
+	if err = w.Copy(list[1]); err != ErrCopyUnsupported {
+		t.Errorf("Copy(%s) error = %v, want %v", list[1].Name, err, ErrCopyUnsupported)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenReader(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	h, err := r.Next()
+	if err != nil || h.Name != "s.txt" || h.HostOS != HostOSWindows {
+		t.Fatalf("Next = %+v, %v", h, err)
+	}
+	if b, err := io.ReadAll(r); err != nil || string(b) != "stored" {
+		t.Errorf("read s.txt = %q, %v", b, err)
+	}
+}
@@ -0,0 +1,22 @@
+package rardecode
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// WriteStreamXattrs stores the contents of streams as user.* extended attributes
+// of the file path, using the stream name as the attribute name.
+func WriteStreamXattrs(path string, streams []*Stream) error {
+	for _, s := range streams {
+		b, err := s.readAll()
+		if err != nil {
+			return err
+		}
+		err = syscall.Setxattr(path, "user."+s.Name, b, 0)
+		if err != nil {
+			return &fs.PathError{Op: "setxattr", Path: path, Err: err}
+		}
+	}
+	return nil
+}
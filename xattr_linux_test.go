@@ -0,0 +1,45 @@
+package rardecode
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWriteStreamXattrs(t *testing.T) {
+	zone := []byte("ZoneId=3")
+	rec := append([]byte{7}, ":Zone.Identifier:$DATA"...)
+	extra := append([]byte{byte(len(rec))}, rec...)
+	arc := []byte(sig50)
+	arc = append(arc, rar5Block(block5Arc, 0, []byte{0}, nil, nil)...)
+	arc = append(arc, rar5Block(block5File, 0, rar5FileData("a.txt", nil), nil, nil)...)
+	arc = append(arc, rar5Block(block5Service, 0, rar5FileData(serviceStream, zone), extra, zone)...)
+	arc = append(arc, rar5Block(block5End, 0, []byte{0}, nil, nil)...)
+
+	files, err := List("arc.rar", FileSystem(fstest.MapFS{"arc.rar": {Data: arc}}))
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	streams, err := files[0].Streams()
+	if err != nil {
+		t.Fatalf("Streams() error = %v", err)
+	}
+	name := filepath.Join(t.TempDir(), "a.txt")
+	if err = os.WriteFile(name, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = WriteStreamXattrs(name, streams)
+	if errors.Is(err, syscall.ENOTSUP) {
+		t.Skip("extended attributes not supported by file system")
+	} else if err != nil {
+		t.Fatalf("WriteStreamXattrs() error = %v", err)
+	}
+	b := make([]byte, 64)
+	n, err := syscall.Getxattr(name, "user.Zone.Identifier", b)
+	if err != nil || string(b[:n]) != string(zone) {
+		t.Errorf("Getxattr() = %q, %v, want %q", b[:n], err, zone)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package rardecode
+
+// WriteStreamXattrs stores the contents of streams as user.* extended attributes
+// of the file path. It is only supported on Linux, other platforms return
+// ErrXattrUnsupported.
+func WriteStreamXattrs(path string, streams []*Stream) error {
+	return ErrXattrUnsupported
+}